@@ -0,0 +1,22 @@
+package chow
+
+import (
+	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// GenerateBoundEncryptionKeys is GenerateEncryptionKeys, but the construction is bound to the device with the given
+// fingerprint: it only computes AES when the device mask of that fingerprint is added to its input, as
+// common.BoundBlock does. On any other device, it computes a different function.
+func GenerateBoundEncryptionKeys(key, seed, fingerprint []byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	device := common.DeviceMask(fingerprint)
+	return generateEncryptionKeys(key, seed, &device, opts)
+}
+
+// GenerateBoundDecryptionKeys is GenerateDecryptionKeys, but the construction is bound to the device with the given
+// fingerprint. See GenerateBoundEncryptionKeys.
+func GenerateBoundDecryptionKeys(key, seed, fingerprint []byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	device := common.DeviceMask(fingerprint)
+	return generateDecryptionKeys(key, seed, &device, opts)
+}
@@ -32,41 +32,32 @@ func (constr Construction) BlockSize() int { return 16 }
 
 // Encrypt encrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Encrypt(dst, src []byte) {
-	constr.crypt(dst, src, constr.shiftRows)
+	constr.crypt(dst, src, constr.shiftRows, nil)
 }
 
 // Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Decrypt(dst, src []byte) {
-	constr.crypt(dst, src, constr.unShiftRows)
+	constr.crypt(dst, src, constr.unShiftRows, nil)
 }
 
 // crypt pushes the first block in src through the lookup tables (which may compute encryption or decryption) and writes
-// the result to dst. shift is the permutation to apply to the state matrix before each round.
-func (constr Construction) crypt(dst, src []byte, shift func([]byte)) {
+// the result to dst. shift is the permutation to apply to the state matrix before each round. If t isn't nil, it's told
+// about every lookup and the state after every round.
+func (constr Construction) crypt(dst, src []byte, shift func([]byte), t common.Tracer) {
 	copy(dst, src[:constr.BlockSize()])
 
 	// Remove input encoding.
-	stretched := constr.expandBlock(constr.InputMask, dst)
-	constr.InputXORTables.SquashBlocks(stretched, dst)
+	constr.prologue(t).Encrypt(dst, dst)
 
 	for round := 0; round < 9; round++ {
 		shift(dst)
-
-		// Apply the T-Boxes and Tyi Tables to each column of the state matrix.
-		for pos := 0; pos < 16; pos += 4 {
-			stretched := constr.ExpandWord(constr.TBoxTyiTable[round][pos:pos+4], dst[pos:pos+4])
-			constr.SquashWords(constr.HighXORTable[round][2*pos:2*pos+8], stretched, dst[pos:pos+4])
-
-			stretched = constr.ExpandWord(constr.MBInverseTable[round][pos:pos+4], dst[pos:pos+4])
-			constr.SquashWords(constr.LowXORTable[round][2*pos:2*pos+8], stretched, dst[pos:pos+4])
-		}
+		constr.round(round, t).Encrypt(dst, dst)
 	}
 
 	shift(dst)
 
 	// Apply the final T-Box transformation and add the output encoding.
-	stretched = constr.expandBlock(constr.TBoxOutputMask, dst)
-	constr.OutputXORTables.SquashBlocks(stretched, dst)
+	constr.epilogue(t).Encrypt(dst, dst)
 }
 
 // shiftRows permutes the bytes of the first block of block, according to AES' ShiftRows operation.
@@ -85,31 +76,12 @@ func (constr *Construction) unShiftRows(block []byte) {
 	})
 }
 
-// ExpandWord expands one word of the state matrix with the T-Boxes composed with Tyi Tables.
+// ExpandWord expands one word of the state matrix with the T-Boxes composed with Tyi Tables. See common.ExpandWord.
 func (constr *Construction) ExpandWord(tboxtyi []table.Word, word []byte) [4][4]byte {
-	return [4][4]byte{tboxtyi[0].Get(word[0]), tboxtyi[1].Get(word[1]), tboxtyi[2].Get(word[2]), tboxtyi[3].Get(word[3])}
+	return common.ExpandWord(tboxtyi, word)
 }
 
-// SquashWords squashes an expanded word back into one word with 3 pairwise XORs (calc'd one nibble at a time):
-//   (((a ^ b) ^ c) ^ d)
+// SquashWords squashes an expanded word back into one word with 3 pairwise XORs. See common.SquashWords.
 func (constr *Construction) SquashWords(xorTable [][3]table.Nibble, words [4][4]byte, dst []byte) {
-	copy(dst, words[0][:])
-
-	for i := 1; i < 4; i++ {
-		for pos := 0; pos < 4; pos++ {
-			aPartial := dst[pos]&0xf0 | (words[i][pos]&0xf0)>>4
-			bPartial := (dst[pos]&0x0f)<<4 | words[i][pos]&0x0f
-
-			dst[pos] = xorTable[2*pos+0][i-1].Get(aPartial)<<4 | xorTable[2*pos+1][i-1].Get(bPartial)
-		}
-	}
-}
-
-// ExpandBlock expands the entire state matrix into sixteen blocks.
-func (constr *Construction) expandBlock(mask [16]table.Block, block []byte) (out [16][16]byte) {
-	for i := 0; i < 16; i++ {
-		out[i] = mask[i].Get(block[i])
-	}
-
-	return
+	common.SquashWords(xorTable, words, dst)
 }
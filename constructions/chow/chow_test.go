@@ -3,11 +3,15 @@ package chow
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/rand"
 	"testing"
 
 	"github.com/OpenWhiteBox/primitives/matrix"
 
 	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/internal/tracetest"
+	"github.com/OpenWhiteBox/AES/constructions/network"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
 
 	test_vectors "github.com/OpenWhiteBox/AES/constructions/test"
 )
@@ -119,6 +123,34 @@ func TestDecrypt(t *testing.T) {
 	}
 }
 
+func TestExternalEncodings(t *testing.T) {
+	opts := common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.NonlinearEncoding, common.NonlinearEncoding,
+	}
+
+	for n, vec := range test_vectors.GetAESVectors(testing.Short()) {
+		constr, inputMask, outputMask := GenerateEncryptionKeys(vec.Key, vec.Key, opts)
+		inputEnc, outputEnc := common.GenerateExternalEncodings(vec.Key, opts)
+
+		inputInv, _ := inputMask.Invert()
+		outputInv, _ := outputMask.Invert()
+
+		in, out := [16]byte{}, [16]byte{}
+
+		copy(in[:], inputInv.Mul(matrix.Row(vec.In))) // Apply input encoding.
+		in = inputEnc.Encode(in)
+
+		constr.Encrypt(out[:], in[:])
+
+		out = outputEnc.Decode(out) // Remove output encoding.
+		copy(out[:], outputInv.Mul(matrix.Row(out[:])))
+
+		if !bytes.Equal(vec.Out, out[:]) {
+			t.Fatalf("Real disagrees with result in test vector %v! %x != %x", n, vec.Out, out)
+		}
+	}
+}
+
 func TestPersistence(t *testing.T) {
 	constr1, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
 
@@ -140,6 +172,84 @@ func TestPersistence(t *testing.T) {
 	}
 }
 
+func TestWatermark(t *testing.T) {
+	id := uint32(0xdeadbeef)
+
+	constr1, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	constr2, _, _ := GenerateWatermarkedEncryptionKeys(
+		key, seed, id, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	cand1, cand2 := make([]byte, 16), make([]byte, 16)
+
+	constr1.Encrypt(cand1, input)
+	constr2.Encrypt(cand2, input)
+
+	if !bytes.Equal(cand1, cand2) {
+		t.Fatalf("Watermarked construction computes a different function! %x != %x", cand1, cand2)
+	}
+
+	cand, confidence := Trace(constr2.Serialize(), seed)
+	if cand != id || confidence != 1 {
+		t.Fatalf("Trace returned wrong identifier! %x != %x (confidence %v)", id, cand, confidence)
+	}
+
+	if _, confidence := Trace(constr2.Serialize(), key); confidence != 0 {
+		t.Fatalf("Trace found a watermark with the wrong seed! (confidence %v)", confidence)
+	}
+}
+
+func TestWatermarkDecryption(t *testing.T) {
+	id := uint32(0x8badf00d)
+
+	constr, _, _ := GenerateWatermarkedDecryptionKeys(
+		key, seed, id, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	cand, confidence := Trace(constr.Serialize(), seed)
+	if cand != id || confidence != 1 {
+		t.Fatalf("Trace returned wrong identifier! %x != %x (confidence %v)", id, cand, confidence)
+	}
+}
+
+func TestOracleWatermark(t *testing.T) {
+	id, opts := uint32(0xdeadbeef), common.IndependentMasks{common.RandomMask, common.RandomMask}
+
+	constr, _, _ := GenerateOracleWatermarkedEncryptionKeys(key, seed, id, opts)
+	constr, _ = Parse(constr.Serialize())
+
+	cand, confidence := TraceEncryptionOracle(constr, key, seed, opts)
+	if cand != id || confidence != 1 {
+		t.Fatalf("TraceEncryptionOracle returned wrong identifier! %x != %x (confidence %v)", id, cand, confidence)
+	}
+
+	rerand := RerandomizeEncryption(constr, key)
+	rerand, _ = Parse(rerand.Serialize())
+
+	cand, confidence = TraceEncryptionOracle(rerand, key, seed, opts)
+	if cand != id || confidence != 1 {
+		t.Fatalf("Re-randomization erased the watermark! %x != %x (confidence %v)", id, cand, confidence)
+	}
+
+	plain, _, _ := GenerateEncryptionKeys(key, seed, opts)
+	plain, _ = Parse(plain.Serialize())
+	if _, confidence := TraceEncryptionOracle(plain, key, seed, opts); confidence != 0 {
+		t.Fatalf("TraceEncryptionOracle found a watermark in an unwatermarked instance! (confidence %v)", confidence)
+	}
+}
+
+func TestOracleWatermarkDecryption(t *testing.T) {
+	id, opts := uint32(0x8badf00d), common.IndependentMasks{common.RandomMask, common.RandomMask}
+
+	constr, _, _ := GenerateOracleWatermarkedDecryptionKeys(key, seed, id, opts)
+	constr, _ = Parse(constr.Serialize())
+
+	cand, confidence := TraceDecryptionOracle(constr, key, seed, opts)
+	if cand != id || confidence != 1 {
+		t.Fatalf("TraceDecryptionOracle returned wrong identifier! %x != %x (confidence %v)", id, cand, confidence)
+	}
+}
+
 func BenchmarkGenerateEncryptionKeys(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		constr, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
@@ -175,3 +285,259 @@ func BenchmarkDeadEncrypt(b *testing.B) {
 		constr2.Encrypt(out, input)
 	}
 }
+
+func TestBoundEncrypt(t *testing.T) {
+	fingerprint, other := []byte("device-1"), []byte("device-2")
+
+	constr, inputMask, outputMask := GenerateBoundEncryptionKeys(
+		key, seed, fingerprint, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	real := make([]byte, 16)
+	c, _ := aes.NewCipher(key)
+	c.Encrypt(real, input)
+
+	for _, device := range [][]byte{fingerprint, other} {
+		in, out := make([]byte, 16), make([]byte, 16)
+
+		copy(in, inputInv.Mul(matrix.Row(input))) // Apply input encoding.
+
+		common.BoundBlock{constr, device}.Encrypt(out, in)
+
+		copy(out, outputInv.Mul(matrix.Row(out))) // Remove output encoding.
+
+		if same := bytes.Equal(real, out); same != bytes.Equal(device, fingerprint) {
+			t.Fatalf("Bound construction computed the wrong function on device %q! %x, %x", device, real, out)
+		}
+	}
+}
+
+func TestBoundDecrypt(t *testing.T) {
+	fingerprint := []byte("device-1")
+
+	constr, inputMask, outputMask := GenerateBoundDecryptionKeys(
+		key, seed, fingerprint, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	in, out := make([]byte, 16), make([]byte, 16)
+	c, _ := aes.NewCipher(key)
+	c.Encrypt(in, input)
+
+	copy(in, inputInv.Mul(matrix.Row(in))) // Apply input encoding.
+
+	common.BoundBlock{constr, fingerprint}.Decrypt(out, in)
+
+	copy(out, outputInv.Mul(matrix.Row(out))) // Remove output encoding.
+
+	if !bytes.Equal(input, out) {
+		t.Fatalf("Real disagrees with result! %x != %x", input, out)
+	}
+}
+
+func TestBoundExternalEncodings(t *testing.T) {
+	fingerprint, other := []byte("device-1"), []byte("device-2")
+	opts := common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.NonlinearEncoding, common.NonlinearEncoding,
+	}
+
+	constr, inputMask, outputMask := GenerateBoundEncryptionKeys(key, seed, fingerprint, opts)
+	inputEnc, outputEnc := common.GenerateExternalEncodings(seed, opts)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	real := make([]byte, 16)
+	c, _ := aes.NewCipher(key)
+	c.Encrypt(real, input)
+
+	for _, device := range [][]byte{fingerprint, other} {
+		in, out := [16]byte{}, [16]byte{}
+
+		copy(in[:], inputInv.Mul(matrix.Row(input))) // Apply input encoding.
+		in = inputEnc.Encode(in)
+
+		common.BoundBlock{constr, device}.Encrypt(out[:], in[:])
+
+		out = outputEnc.Decode(out) // Remove output encoding.
+		copy(out[:], outputInv.Mul(matrix.Row(out[:])))
+
+		if same := bytes.Equal(real, out[:]); same != bytes.Equal(device, fingerprint) {
+			t.Fatalf("Bound construction computed the wrong function on device %q! %x, %x", device, real, out)
+		}
+	}
+}
+
+func TestRerandomize(t *testing.T) {
+	constr1, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	constr2 := RerandomizeEncryption(constr1, key)
+
+	if bytes.Equal(constr1.Serialize(), constr2.Serialize()) {
+		t.Fatalf("Rerandomize didn't change the construction!")
+	}
+
+	cand1, cand2 := make([]byte, 16), make([]byte, 16)
+
+	constr1.Encrypt(cand1, input)
+	constr2.Encrypt(cand2, input)
+
+	if !bytes.Equal(cand1, cand2) {
+		t.Fatalf("Real disagrees with rerandomized! %x != %x", cand1, cand2)
+	}
+
+	constr3, _, _ := GenerateDecryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	constr4 := RerandomizeDecryption(constr3, key)
+
+	constr3.Decrypt(cand1, input)
+	constr4.Decrypt(cand2, input)
+
+	if !bytes.Equal(cand1, cand2) {
+		t.Fatalf("Real disagrees with rerandomized! %x != %x", cand1, cand2)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	opts := common.IndependentMasks{common.RandomMask, common.RandomMask}
+
+	constr1, _, _ := GenerateEncryptionKeys(key, seed, opts)
+	constr2 := RefreshEncryption(constr1, seed, key)
+	constr3 := RefreshEncryption(constr1, key, key)
+
+	if bytes.Equal(constr1.Serialize(), constr2.Serialize()) {
+		t.Fatalf("Refresh didn't change the construction!")
+	}
+
+	constr4, _, _ := GenerateDecryptionKeys(key, seed, opts)
+	constr5 := RefreshDecryption(constr4, seed, key)
+
+	real, cand, wrong := make([]byte, 16), make([]byte, 16), make([]byte, 16)
+	for i := 0; i < 16; i++ {
+		block := make([]byte, 16)
+		rand.Read(block)
+
+		constr1.Encrypt(real, block)
+		constr2.Encrypt(cand, block)
+		constr3.Encrypt(wrong, block)
+
+		if !bytes.Equal(real, cand) {
+			t.Fatalf("Real disagrees with refreshed! %x != %x", real, cand)
+		} else if bytes.Equal(real, wrong) {
+			t.Fatalf("Refreshing with the wrong original seed didn't change the function!")
+		}
+
+		constr4.Decrypt(real, block)
+		constr5.Decrypt(cand, block)
+
+		if !bytes.Equal(real, cand) {
+			t.Fatalf("Real disagrees with refreshed! %x != %x", real, cand)
+		}
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	constr, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	n, err := network.Parse(constr.EncryptionNetwork().Serialize())
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+
+	constr.Encrypt(real, input)
+	n.Encrypt(cand, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with network! %x != %x", real, cand)
+	}
+}
+
+func TestDecryptionNetwork(t *testing.T) {
+	constr, _, _ := GenerateDecryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	n, err := network.Parse(constr.DecryptionNetwork().Serialize())
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+
+	constr.Decrypt(real, input)
+	n.Encrypt(cand, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with network! %x != %x", real, cand)
+	}
+}
+
+func TestRounds(t *testing.T) {
+	constr, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	base := saes.Construction{}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+	constr.Encrypt(real, input)
+
+	constr.Prologue().Encrypt(cand, input)
+	for round := 0; round < 9; round++ {
+		base.ShiftRows(cand)
+		constr.Round(round).Encrypt(cand, cand)
+	}
+	base.ShiftRows(cand)
+	constr.Epilogue().Encrypt(cand, cand)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with composed rounds! %x != %x", real, cand)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	opts := common.IndependentMasks{common.RandomMask, common.RandomMask}
+	constr, inputMask, outputMask := GenerateEncryptionKeys(key, seed, opts)
+
+	if err := Debug(&constr, key, seed, opts, inputMask, outputMask, input); err != nil {
+		t.Fatalf("Debugger found a divergence in a correct construction: %v", err)
+	}
+
+	// Break one T-Box/Tyi table in round 4.
+	constr.TBoxTyiTable[4][5] = constr.TBoxTyiTable[4][6]
+
+	err, ok := Debug(&constr, key, seed, opts, inputMask, outputMask, input).(*common.Divergence)
+	if !ok {
+		t.Fatalf("Debugger didn't find a divergence in a broken construction!")
+	} else if err.Stage != 5 {
+		t.Fatalf("Debugger found divergence at the wrong stage! %v != 5", err.Stage)
+	}
+}
+
+func TestDebugExternal(t *testing.T) {
+	opts := common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.NonlinearEncoding, common.NonlinearEncoding,
+	}
+	constr, inputMask, outputMask := GenerateEncryptionKeys(key, seed, opts)
+
+	if err := Debug(&constr, key, seed, opts, inputMask, outputMask, input); err != nil {
+		t.Fatalf("Debugger found a divergence in a correct construction: %v", err)
+	}
+}
+
+func TestTraced(t *testing.T) {
+	constr, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	tracer := &tracetest.Counter{}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+	constr.Encrypt(real, input)
+	constr.Traced(tracer).Encrypt(cand, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with result! %x != %x", real, cand)
+	} else if tracer.Lookups != 3008 {
+		t.Fatalf("Tracer saw the wrong number of lookups! %v != 3008", tracer.Lookups)
+	} else if len(tracer.Rounds) != 11 || tracer.Rounds[0] != -1 || tracer.Rounds[10] != 9 {
+		t.Fatalf("Tracer saw the state after the wrong rounds! %v", tracer.Rounds)
+	} else if !bytes.Equal(tracer.Last[:16], cand) {
+		t.Fatalf("Tracer's last state isn't the output! %x != %x", tracer.Last, cand)
+	}
+}
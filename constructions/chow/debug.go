@@ -0,0 +1,70 @@
+package chow
+
+import (
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/matrix"
+	"github.com/OpenWhiteBox/primitives/random"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// DecodeStates pushes the plaintext block through an encryption construction and decodes the state after its Prologue
+// (stage 0), after each Round(r) (stage r+1), and after its Epilogue (stage 10) into common.States. The internal and
+// external encodings are regenerated from the seed and opts the construction was generated with; the masks are the ones
+// it returned.
+//
+// Only encryption constructions are supported. A decryption construction's encodings come from a different random
+// source, and its rounds are the inverse cipher's, which common.States doesn't describe.
+func DecodeStates(constr *Construction, seed []byte, opts common.KeyGenerationOpts, inputMask, outputMask matrix.Matrix, block []byte) (out common.States) {
+	rs := random.NewSource("Chow Encryption", seed)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	input, output := common.GenerateExternalEncodings(seed, opts)
+
+	in := [16]byte{}
+	copy(in[:], inputInv.Mul(matrix.Row(block[:16])))
+	in = input.Encode(in)
+	state := in[:]
+
+	// The state after a round is under the round's encodings, on top of the mixing bijections of the next round's
+	// T-Box/Tyi tables. Both are indexed by where the byte ends up after ShiftRows.
+	decode := func(round int, dst []byte) {
+		for pos := 0; pos < 16; pos++ {
+			enc := encoding.ComposedBytes{
+				encoding.NewByteLinear(common.MixingBijection(&rs, 8, round, common.ShiftRows(pos))),
+				byteRoundEncoding(&rs, round, pos, common.Outside, common.ShiftRows),
+			}
+
+			dst[pos] = enc.Decode(state[pos])
+		}
+	}
+
+	constr.Prologue().Encrypt(state, state)
+	decode(-1, out[0][:])
+
+	for round := 0; round < 9; round++ {
+		constr.shiftRows(state)
+		constr.Round(round).Encrypt(state, state)
+		decode(round, out[round+1][:])
+	}
+
+	constr.shiftRows(state)
+	constr.Epilogue().Encrypt(state, state)
+	copy(out[10][:], state)
+	out[10] = output.Decode(out[10])
+	copy(out[10][:], outputInv.Mul(matrix.Row(out[10][:])))
+
+	return
+}
+
+// Debug checks an encryption construction against AES with the given key, round by round, on the plaintext block. It
+// returns a *common.Divergence locating the first byte of the decoded state that's wrong, or nil if there is none. See
+// DecodeStates for why decryption constructions aren't supported.
+func Debug(constr *Construction, key, seed []byte, opts common.KeyGenerationOpts, inputMask, outputMask matrix.Matrix, block []byte) error {
+	return common.Compare(
+		common.ExpectedStates(key, block),
+		DecodeStates(constr, seed, opts, inputMask, outputMask, block),
+	)
+}
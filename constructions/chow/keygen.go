@@ -10,14 +10,24 @@ import (
 	"github.com/OpenWhiteBox/AES/constructions/saes"
 )
 
-func generateKeys(rs *random.Source, opts common.KeyGenerationOpts, out *Construction, inputMask, outputMask *matrix.Matrix, shift func(int) int, skinny func(int) table.Byte, wide func(int, int) table.Word) {
-	// Generate input and output encodings.
-	common.GenerateMasks(rs, opts, inputMask, outputMask)
+// generateKeys generates the tables of a construction. If device isn't nil, the construction is bound to the device mask
+// it points to: the mask is removed from the input before the input encoding is, so that binding works whatever the
+// encoding is.
+func generateKeys(rs *random.Source, seed []byte, device *[16]byte, opts common.KeyGenerationOpts, out *Construction, inputMask, outputMask *matrix.Matrix, shift func(int) int, skinny func(int) table.Byte, wide func(int, int) table.Word) {
+	// Generate input and output encodings. The external encodings are merged into the first and last tables.
+	common.GenerateMasks(rs, common.MaskOpts(opts), inputMask, outputMask)
+	input, output := common.GenerateExternalEncodings(seed, opts)
+
+	if device != nil {
+		for pos := 0; pos < 16; pos++ {
+			input[pos] = encoding.ComposedBytes{input[pos], encoding.ByteAdditive(device[pos])}
+		}
+	}
 
 	// Generate the Input Mask slices and XOR tables.
 	for pos := 0; pos < 16; pos++ {
 		out.InputMask[pos] = encoding.BlockTable{
-			encoding.IdentityByte{},
+			input[pos],
 			blockMaskEncoding(rs, pos, common.Inside, shift),
 			common.BlockMatrix{Linear: *inputMask, Position: pos},
 		}
@@ -32,36 +42,15 @@ func generateKeys(rs *random.Source, opts common.KeyGenerationOpts, out *Constru
 	// Generate round material.
 	for round := 0; round < 9; round++ {
 		for pos := 0; pos < 16; pos++ {
-			// Generate a word-sized mixing bijection and stick it on the end of the T-Box/Tyi Table.
-			mb := common.MixingBijection(rs, 32, round, pos/4)
-
-			// Build the T-Box and Tyi Table for this round and position in the state matrix.
+			// Build the T-Box and Tyi Table for this round and position in the state matrix. A word-sized mixing bijection
+			// is stuck on the end of it and inverted in the MB^(-1) table for this round and position.
 			out.TBoxTyiTable[round][pos] = encoding.WordTable{
-				encoding.ComposedBytes{
-					encoding.NewByteLinear(common.MixingBijection(rs, 8, round-1, pos)),
-					byteRoundEncoding(rs, round-1, pos, common.Outside, common.NoShift),
-				},
-				encoding.ComposedWords{
-					encoding.ConcatenatedWord{
-						encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(pos/4*4+0))),
-						encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(pos/4*4+1))),
-						encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(pos/4*4+2))),
-						encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(pos/4*4+3))),
-					},
-					encoding.NewWordLinear(mb),
-					wordStepEncoding(rs, round, pos, common.Inside),
-				},
+				stepInputEncoding(rs, round, pos),
+				stepOutputEncoding(rs, round, pos, shift),
 				wide(round, pos),
 			}
 
-			// Encode the inverse of the mixing bijection from above in the MB^(-1) table for this round and position.
-			mbInv, _ := mb.Invert()
-
-			out.MBInverseTable[round][pos] = encoding.WordTable{
-				byteRoundEncoding(rs, round, pos, common.Inside, common.NoShift),
-				wordStepEncoding(rs, round, pos, common.Outside),
-				mbInverseTable{mbInv, uint(pos) % 4},
-			}
+			out.MBInverseTable[round][pos] = generateMBInverseTable(rs, round, pos)
 		}
 	}
 
@@ -72,10 +61,7 @@ func generateKeys(rs *random.Source, opts common.KeyGenerationOpts, out *Constru
 	// Generate the 10th T-Box/Output Mask slices and XOR tables.
 	for pos := 0; pos < 16; pos++ {
 		out.TBoxOutputMask[pos] = encoding.BlockTable{
-			encoding.ComposedBytes{
-				encoding.NewByteLinear(common.MixingBijection(rs, 8, 8, pos)),
-				byteRoundEncoding(rs, 8, pos, common.Outside, common.NoShift),
-			},
+			stepInputEncoding(rs, 9, pos),
 			blockMaskEncoding(rs, pos, common.Outside, shift),
 			table.ComposedToBlock{
 				Heads: skinny(pos),
@@ -87,14 +73,26 @@ func generateKeys(rs *random.Source, opts common.KeyGenerationOpts, out *Constru
 	out.OutputXORTables = common.BlockNibbleXORTables(
 		maskEncoding(rs, common.Outside),
 		xorEncoding(rs, 10, common.Outside),
-		func(position int) encoding.Nibble { return encoding.IdentityByte{} },
+		func(position int) encoding.Nibble {
+			half := output[position/2].(encoding.ConcatenatedByte)
+			if position%2 == 0 {
+				return half.Left
+			}
+			return half.Right
+		},
 	)
 }
 
 // GenerateEncryptionKeys creates a white-boxed version of AES with given key for encryption, with any non-determinism
 // generated by seed. Opts specifies what type of input and output masks we put on the construction and should be in
-// common.{IndependentMasks, SameMasks, MatchingMasks}.
+// common.{IndependentMasks, SameMasks, MatchingMasks}, possibly wrapped in common.ExternalEncodings.
 func GenerateEncryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	return generateEncryptionKeys(key, seed, nil, opts)
+}
+
+// generateEncryptionKeys implements GenerateEncryptionKeys. If device isn't nil, the construction is bound to the
+// device mask it points to.
+func generateEncryptionKeys(key, seed []byte, device *[16]byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
 	rs := random.NewSource("Chow Encryption", seed)
 
 	constr := saes.Construction{key}
@@ -116,15 +114,21 @@ func GenerateEncryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (ou
 		}
 	}
 
-	generateKeys(&rs, opts, &out, &inputMask, &outputMask, common.ShiftRows, skinny, wide)
+	generateKeys(&rs, seed, device, opts, &out, &inputMask, &outputMask, common.ShiftRows, skinny, wide)
 
 	return
 }
 
 // GenerateDecryptionKeys creates a white-boxed version of AES with given key for decryption, with any non-determinism
 // generated by seed. Opts specifies what type of input and output masks we put on the construction and should be in
-// common.{IndependentMasks, SameMasks, MatchingMasks}.
+// common.{IndependentMasks, SameMasks, MatchingMasks}, possibly wrapped in common.ExternalEncodings.
 func GenerateDecryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	return generateDecryptionKeys(key, seed, nil, opts)
+}
+
+// generateDecryptionKeys implements GenerateDecryptionKeys. If device isn't nil, the construction is bound to the
+// device mask it points to.
+func generateDecryptionKeys(key, seed []byte, device *[16]byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
 	rs := random.NewSource("Chow Decryption", seed)
 
 	constr := saes.Construction{key}
@@ -151,7 +155,7 @@ func GenerateDecryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (ou
 		}
 	}
 
-	generateKeys(&rs, opts, &out, &inputMask, &outputMask, common.UnShiftRows, skinny, wide)
+	generateKeys(&rs, seed, device, opts, &out, &inputMask, &outputMask, common.UnShiftRows, skinny, wide)
 
 	return
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/OpenWhiteBox/primitives/encoding"
 	"github.com/OpenWhiteBox/primitives/matrix"
 	"github.com/OpenWhiteBox/primitives/random"
+	"github.com/OpenWhiteBox/primitives/table"
 
 	"github.com/OpenWhiteBox/AES/constructions/common"
 )
@@ -168,3 +169,39 @@ func byteRoundEncoding(rs *random.Source, round, position int, surface common.Su
 		roundEncoding(rs, round, surface, shift)(2*position + 1),
 	}
 }
+
+// stepInputEncoding encodes the input of a T-Box/Tyi Table in the given round, or of a TBoxOutputMask slice if round = 9.
+// It undoes the 8-bit mixing bijection of the previous round.
+func stepInputEncoding(rs *random.Source, round, position int) encoding.Byte {
+	return encoding.ComposedBytes{
+		encoding.NewByteLinear(common.MixingBijection(rs, 8, round-1, position)),
+		byteRoundEncoding(rs, round-1, position, common.Outside, common.NoShift),
+	}
+}
+
+// stepOutputEncoding encodes the output of a T-Box/Tyi Table. It applies the 8-bit mixing bijections of the bytes the
+// output is XORed into, the 32-bit mixing bijection of the column, and the step encodings.
+func stepOutputEncoding(rs *random.Source, round, position int, shift func(int) int) encoding.Word {
+	return encoding.ComposedWords{
+		encoding.ConcatenatedWord{
+			encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(position/4*4+0))),
+			encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(position/4*4+1))),
+			encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(position/4*4+2))),
+			encoding.NewByteLinear(common.MixingBijection(rs, 8, round, shift(position/4*4+3))),
+		},
+		encoding.NewWordLinear(common.MixingBijection(rs, 32, round, position/4)),
+		wordStepEncoding(rs, round, position, common.Inside),
+	}
+}
+
+// generateMBInverseTable builds the MB^(-1) Table that inverts the 32-bit mixing bijection of the given round and
+// position's column.
+func generateMBInverseTable(rs *random.Source, round, position int) table.Word {
+	mbInv, _ := common.MixingBijection(rs, 32, round, position/4).Invert()
+
+	return encoding.WordTable{
+		byteRoundEncoding(rs, round, position, common.Inside, common.NoShift),
+		wordStepEncoding(rs, round, position, common.Outside),
+		mbInverseTable{mbInv, uint(position) % 4},
+	}
+}
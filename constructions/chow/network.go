@@ -0,0 +1,84 @@
+package chow
+
+import (
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/network"
+)
+
+// EncryptionNetwork converts an encryption construction into a table network that computes its Encrypt method.
+func (constr *Construction) EncryptionNetwork() *network.Network {
+	return constr.Network(common.ShiftRows)
+}
+
+// DecryptionNetwork converts a decryption construction into a table network whose Encrypt method computes the
+// construction's Decrypt method.
+func (constr *Construction) DecryptionNetwork() *network.Network {
+	return constr.Network(common.UnShiftRows)
+}
+
+// Network converts the construction into a table network. shift is the permutation applied to the state matrix between
+// rounds: common.ShiftRows for encryption or common.UnShiftRows for decryption. See EncryptionNetwork and
+// DecryptionNetwork.
+func (constr *Construction) Network(shift func(int) int) *network.Network {
+	n := network.New()
+
+	blockMatrix := func(name, xorName string, round int, mask [16]table.Block, xors common.NibbleXORTables, state []int) []int {
+		blocks := make([]int, 16)
+		for pos := 0; pos < 16; pos++ {
+			n.At(name, round, pos)
+			blocks[pos] = n.Lookup(n.AddTable(network.FromBlock(mask[pos])), state[pos])
+		}
+
+		n.At(xorName, round, 0)
+		return n.Bytes(n.SquashNibbles(blocks, 16, func(nibble, i int) int {
+			return n.AddTable(network.FromNibble(xors[nibble][i]))
+		}), 16)
+	}
+
+	steps := func(name, xorName string, round int, tables *[16]table.Word, xors *[32][3]table.Nibble, state []int) []int {
+		out := make([]int, 16)
+
+		for col := 0; col < 16; col += 4 {
+			words := make([]int, 4)
+			for pos := col; pos < col+4; pos++ {
+				n.At(name, round, pos)
+				words[pos-col] = n.Lookup(n.AddTable(network.FromWord(tables[pos])), state[pos])
+			}
+
+			n.At(xorName, round, col)
+			copy(out[col:], n.Bytes(n.SquashNibbles(words, 4, func(nibble, i int) int {
+				return n.AddTable(network.FromNibble(xors[2*col+nibble][i]))
+			}), 4))
+		}
+
+		return out
+	}
+
+	permute := func(state []int) []int {
+		out := make([]int, 16)
+		for pos, b := range state {
+			out[shift(pos)] = b
+		}
+
+		return out
+	}
+
+	state := blockMatrix("InputMask", "InputXORTables", -1, constr.InputMask, constr.InputXORTables, n.Bytes(n.Input(), 16))
+
+	for round := 0; round < 9; round++ {
+		state = permute(state)
+		state = steps("TBoxTyi", "HighXOR", round, &constr.TBoxTyiTable[round], &constr.HighXORTable[round], state)
+		state = steps("MBInverse", "LowXOR", round, &constr.MBInverseTable[round], &constr.LowXORTable[round], state)
+	}
+
+	state = blockMatrix(
+		"TBoxOutputMask", "OutputXORTables", 9, constr.TBoxOutputMask, constr.OutputXORTables, permute(state),
+	)
+
+	n.At("Output", 9, -1)
+	n.Output = n.Concat(state...)
+
+	return n
+}
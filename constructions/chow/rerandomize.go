@@ -0,0 +1,253 @@
+package chow
+
+import (
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/random"
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// wireEncoding is the fresh nibble encoding put on one internal wire of a re-randomized construction. kind names the
+// tables that produce the wire; the other parameters locate it among them.
+func wireEncoding(rs *random.Source, kind byte, a, b, c int) encoding.Nibble {
+	label := make([]byte, 16)
+	label[0], label[1], label[2], label[3], label[4], label[5] = 'R', 'R', kind, byte(a), byte(b), byte(c)
+
+	return rs.Shuffle(label)
+}
+
+// byteEncoding concatenates two nibble encodings into a byte encoding. The first is on the high nibble.
+func byteEncoding(high, low encoding.Nibble) encoding.Byte {
+	return encoding.ConcatenatedByte{high, low}
+}
+
+// reencodeXORTable re-encodes a chain of nibble-wise XOR gates. in(gate) is the fresh encoding on the second input of
+// the given gate (or on the first input too, when gate = -1), and out(gate) is the fresh encoding of the given gate's
+// output.
+func reencodeXORTable(gates []table.Nibble, in, out func(gate int) encoding.Nibble) {
+	for gate := range gates {
+		acc := in(-1)
+		if gate > 0 {
+			acc = out(gate - 1)
+		}
+
+		gates[gate] = encoding.NibbleTable{byteEncoding(acc, in(gate)), out(gate), gates[gate]}
+	}
+}
+
+// reencodeSteps re-encodes one round of TBoxTyiTables or MBInverseTables and the XOR tables that squash their output.
+// state(pos) is the fresh encoding on the input byte at the given position; step is the kind of the step tables, gate is
+// the kind of the XOR tables, and next(pos) is the fresh encoding on the XOR tables' final output.
+func reencodeSteps(rs *random.Source, round int, steps *[16]table.Word, xors *[32][3]table.Nibble, state func(int) encoding.Byte, step, gate byte, next func(int) encoding.Nibble) {
+	for pos := 0; pos < 16; pos++ {
+		out := encoding.ConcatenatedWord{}
+		for i := 0; i < 4; i++ {
+			out[i] = byteEncoding(wireEncoding(rs, step, round, pos, 2*i+0), wireEncoding(rs, step, round, pos, 2*i+1))
+		}
+
+		steps[pos] = encoding.WordTable{state(pos), out, steps[pos]}
+	}
+
+	for pos := 0; pos < 32; pos++ {
+		// Nibble pos of the state comes from nibble pos%8 of the step tables in its column.
+		col, sub := pos/8*4, pos%8
+
+		reencodeXORTable(
+			xors[pos][:],
+			func(g int) encoding.Nibble { return wireEncoding(rs, step, round, col+g+1, sub) },
+			func(g int) encoding.Nibble {
+				if g == 2 {
+					return next(pos)
+				}
+				return wireEncoding(rs, gate, round, pos, g)
+			},
+		)
+	}
+}
+
+// reencodeBlockMatrix re-encodes the slices of an InputMask or TBoxOutputMask and their XOR tables. state(pos) is the
+// fresh encoding on the input byte of the given slice; slice and gate are the kinds of the slices and XOR tables, and
+// next(pos) is the fresh encoding on the XOR tables' final output.
+func reencodeBlockMatrix(rs *random.Source, mask *[16]table.Block, xors *common.NibbleXORTables, state func(int) encoding.Byte, slice, gate byte, next func(int) encoding.Nibble) {
+	for pos := 0; pos < 16; pos++ {
+		out := encoding.ConcatenatedBlock{}
+		for i := 0; i < 16; i++ {
+			out[i] = byteEncoding(wireEncoding(rs, slice, pos, 2*i+0, 0), wireEncoding(rs, slice, pos, 2*i+1, 0))
+		}
+
+		mask[pos] = encoding.BlockTable{state(pos), out, mask[pos]}
+	}
+
+	for pos := 0; pos < 32; pos++ {
+		reencodeXORTable(
+			xors[pos][:],
+			func(g int) encoding.Nibble { return wireEncoding(rs, slice, g+1, pos, 0) },
+			func(g int) encoding.Nibble {
+				if g == 14 {
+					return next(pos)
+				}
+				return wireEncoding(rs, gate, pos, g, 0)
+			},
+		)
+	}
+}
+
+// rerandomize implements RerandomizeEncryption and RerandomizeDecryption. shift is the permutation applied to the state
+// matrix between rounds.
+func rerandomize(rs *random.Source, constr Construction, shift func(int) int) Construction {
+	// stateEncoding is the fresh encoding on the state after the given round, by its position in the next round.
+	// shifted is the same encoding, by its position before the state is shifted.
+	stateEncoding := func(round int) func(int) encoding.Nibble {
+		return func(pos int) encoding.Nibble { return wireEncoding(rs, 'S', round+1, pos, 0) }
+	}
+	shifted := func(round int) func(int) encoding.Nibble {
+		return func(pos int) encoding.Nibble { return stateEncoding(round)(2*shift(pos/2) + pos%2) }
+	}
+	stateByte := func(enc func(int) encoding.Nibble) func(int) encoding.Byte {
+		return func(pos int) encoding.Byte { return byteEncoding(enc(2*pos+0), enc(2*pos+1)) }
+	}
+	identityByte := func(_ int) encoding.Byte { return encoding.IdentityByte{} }
+	identityNibble := func(_ int) encoding.Nibble { return encoding.IdentityByte{} }
+
+	reencodeBlockMatrix(rs, &constr.InputMask, &constr.InputXORTables, identityByte, 'I', 'i', shifted(-1))
+
+	for round := 0; round < 9; round++ {
+		// The High XOR tables' output isn't shifted before it reaches the MB^(-1) tables.
+		high := func(pos int) encoding.Nibble { return wireEncoding(rs, 'H', round, pos, 2) }
+
+		reencodeSteps(
+			rs, round, &constr.TBoxTyiTable[round], &constr.HighXORTable[round],
+			stateByte(stateEncoding(round-1)), 'T', 'H', high,
+		)
+		reencodeSteps(
+			rs, round, &constr.MBInverseTable[round], &constr.LowXORTable[round],
+			stateByte(high), 'M', 'L', shifted(round),
+		)
+	}
+
+	// The output of the OutputXORTables is the construction's output, so it keeps its encoding.
+	reencodeBlockMatrix(
+		rs, &constr.TBoxOutputMask, &constr.OutputXORTables, stateByte(stateEncoding(8)), 'O', 'o', identityNibble,
+	)
+
+	// Flatten the re-encoded tables.
+	out, _ := Parse(constr.Serialize())
+	return out
+}
+
+// RerandomizeEncryption refreshes the internal nibble encodings of an encryption construction, with any non-determinism
+// generated by seed. A fresh encoding is composed onto the output of every table and its inverse onto the input of the
+// tables that consume it. The returned construction computes the same function with the same external masks, but
+// shares no tables with the original. The AES key isn't needed. The mixing bijections are kept; RefreshEncryption
+// replaces them too, given the seed the construction was generated with.
+func RerandomizeEncryption(constr Construction, seed []byte) Construction {
+	rs := random.NewSource("Chow Rerandomization", seed)
+	return rerandomize(&rs, constr, common.ShiftRows)
+}
+
+// RerandomizeDecryption refreshes the internal nibble encodings of a decryption construction. See
+// RerandomizeEncryption.
+func RerandomizeDecryption(constr Construction, seed []byte) Construction {
+	rs := random.NewSource("Chow Rerandomization", seed)
+	return rerandomize(&rs, constr, common.UnShiftRows)
+}
+
+// reencodeByte moves a byte-wise table boundary from the old encoding to the fresh one.
+func reencodeByte(old, fresh encoding.Byte) encoding.Byte {
+	return encoding.ComposedBytes{encoding.InverseByte{old}, fresh}
+}
+
+// refresh implements RefreshEncryption and RefreshDecryption. old is the random source the construction was generated
+// with and fresh is the one its new encodings and mixing bijections are drawn from.
+func refresh(old, fresh *random.Source, constr Construction, shift func(int) int) Construction {
+	// The InputMask keeps the external input encoding; only its output moves to the fresh 8-bit mixing bijections and
+	// mask encodings. The InputXORTables don't depend on the key, so they're built from scratch.
+	for pos := 0; pos < 16; pos++ {
+		constr.InputMask[pos] = encoding.BlockTable{
+			encoding.IdentityByte{},
+			encoding.ComposedBlocks{
+				encoding.InverseBlock{blockMaskEncoding(old, pos, common.Inside, shift)},
+				blockMaskEncoding(fresh, pos, common.Inside, shift),
+			},
+			constr.InputMask[pos],
+		}
+	}
+
+	constr.InputXORTables = common.BlockNibbleXORTables(
+		maskEncoding(fresh, common.Inside),
+		xorEncoding(fresh, 10, common.Inside),
+		roundEncoding(fresh, -1, common.Outside, shift),
+	)
+
+	// The T-Box/Tyi Tables carry the key, so the old encodings and mixing bijections are stripped off of both sides and
+	// fresh ones are put on. Everything else in a round is built from scratch.
+	for round := 0; round < 9; round++ {
+		for pos := 0; pos < 16; pos++ {
+			constr.TBoxTyiTable[round][pos] = encoding.WordTable{
+				reencodeByte(stepInputEncoding(old, round, pos), stepInputEncoding(fresh, round, pos)),
+				encoding.ComposedWords{
+					encoding.InverseWord{stepOutputEncoding(old, round, pos, shift)},
+					stepOutputEncoding(fresh, round, pos, shift),
+				},
+				constr.TBoxTyiTable[round][pos],
+			}
+
+			constr.MBInverseTable[round][pos] = generateMBInverseTable(fresh, round, pos)
+		}
+	}
+
+	constr.HighXORTable = xorTables(fresh, common.Inside, common.NoShift)
+	constr.LowXORTable = xorTables(fresh, common.Outside, shift)
+
+	for pos := 0; pos < 16; pos++ {
+		constr.TBoxOutputMask[pos] = encoding.BlockTable{
+			reencodeByte(stepInputEncoding(old, 9, pos), stepInputEncoding(fresh, 9, pos)),
+			encoding.ComposedBlocks{
+				encoding.InverseBlock{blockMaskEncoding(old, pos, common.Outside, shift)},
+				blockMaskEncoding(fresh, pos, common.Outside, shift),
+			},
+			constr.TBoxOutputMask[pos],
+		}
+	}
+
+	// The last gate of the OutputXORTables has the external output encoding on its output, so it's kept and only its
+	// inputs move to the fresh encodings.
+	last := constr.OutputXORTables
+	constr.OutputXORTables = common.BlockNibbleXORTables(
+		maskEncoding(fresh, common.Outside),
+		xorEncoding(fresh, 10, common.Outside),
+		func(_ int) encoding.Nibble { return encoding.IdentityByte{} },
+	)
+
+	for pos := 0; pos < 32; pos++ {
+		constr.OutputXORTables[pos][14] = encoding.NibbleTable{
+			encoding.ConcatenatedByte{
+				reencodeByte(xorEncoding(old, 10, common.Outside)(pos, 13), xorEncoding(fresh, 10, common.Outside)(pos, 13)),
+				reencodeByte(maskEncoding(old, common.Outside)(15, pos), maskEncoding(fresh, common.Outside)(15, pos)),
+			},
+			encoding.IdentityByte{},
+			last[pos][14],
+		}
+	}
+
+	// Flatten the re-encoded tables.
+	out, _ := Parse(constr.Serialize())
+	return out
+}
+
+// RefreshEncryption replaces the internal encodings and the 8-bit and 32-bit mixing bijections of an encryption
+// construction with fresh ones generated by seed. original is the seed the construction was generated with; it's needed
+// to strip the old mixing bijections off of the tables that carry the key, but the AES key itself isn't. The returned
+// construction computes the same function with the same external masks and encodings.
+func RefreshEncryption(constr Construction, original, seed []byte) Construction {
+	old, fresh := random.NewSource("Chow Encryption", original), random.NewSource("Chow Refresh", seed)
+	return refresh(&old, &fresh, constr, common.ShiftRows)
+}
+
+// RefreshDecryption replaces the internal encodings and mixing bijections of a decryption construction. See
+// RefreshEncryption.
+func RefreshDecryption(constr Construction, original, seed []byte) Construction {
+	old, fresh := random.NewSource("Chow Decryption", original), random.NewSource("Chow Refresh", seed)
+	return refresh(&old, &fresh, constr, common.UnShiftRows)
+}
@@ -0,0 +1,153 @@
+package chow
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// Prologue returns the part of the construction before the first round: the InputMask and InputXORTables.
+//
+// A construction is its Prologue, then Round(0) through Round(8), then its Epilogue. Before each round and before the
+// Epilogue, Encrypt applies ShiftRows to the state and Decrypt applies UnShiftRows; the parts returned here don't.
+func (constr *Construction) Prologue() cipher.Block {
+	return constr.prologue(nil)
+}
+
+// Round returns round i of the construction, for 0 <= i < 9: the T-Box/Tyi, High XOR, MB^(-1), and Low XOR tables. It
+// computes the same thing whether the construction is for encryption or decryption. See Prologue.
+func (constr *Construction) Round(i int) cipher.Block {
+	return constr.round(i, nil)
+}
+
+// Epilogue returns the part of the construction after the last round: the TBoxOutputMask and OutputXORTables. See
+// Prologue.
+func (constr *Construction) Epilogue() cipher.Block {
+	return constr.epilogue(nil)
+}
+
+// prologue, round, and epilogue implement Prologue, Round, and Epilogue. If t isn't nil, the part they return tells it
+// about every lookup and about the state after it.
+func (constr *Construction) prologue(t common.Tracer) cipher.Block {
+	return maskLayer{&constr.InputMask, &constr.InputXORTables, t, -1, "InputMask", "InputXORTables"}
+}
+
+func (constr *Construction) round(i int, t common.Tracer) cipher.Block {
+	return round{constr, i, t}
+}
+
+func (constr *Construction) epilogue(t common.Tracer) cipher.Block {
+	return maskLayer{&constr.TBoxOutputMask, &constr.OutputXORTables, t, 9, "TBoxOutputMask", "OutputXORTables"}
+}
+
+// maskLayer is a Block matrix and the XOR tables that squash its output. If tracer isn't nil, it's told about every
+// lookup, as happening in the given round.
+type maskLayer struct {
+	mask *[16]table.Block
+	xors *common.NibbleXORTables
+
+	tracer            common.Tracer
+	round             int
+	maskKind, xorKind string
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (ml maskLayer) BlockSize() int { return 16 }
+
+// Encrypt pushes the first block in src through the layer and writes the result to dst. Dst and src may point at the
+// same memory.
+func (ml maskLayer) Encrypt(dst, src []byte) {
+	stretched := [16][16]byte{}
+	for i := 0; i < 16; i++ {
+		stretched[i] = ml.mask[i].Get(src[i])
+	}
+
+	if ml.tracer == nil {
+		ml.xors.SquashBlocks(stretched, dst)
+		return
+	}
+
+	for i := 0; i < 16; i++ {
+		ml.tracer.OnLookup(ml.round, i, ml.maskKind, src[i:i+1], stretched[i][:])
+	}
+	ml.xors.TraceSquashBlocks(stretched, dst, ml.tracer, ml.round, ml.xorKind)
+	ml.tracer.OnState(ml.round, dst[:16])
+}
+
+// Decrypt is not implemented.
+func (ml maskLayer) Decrypt(_, _ []byte) {}
+
+// round is one round of a construction. If tracer isn't nil, it's told about every lookup.
+type round struct {
+	constr *Construction
+	round  int
+
+	tracer common.Tracer
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (r round) BlockSize() int { return 16 }
+
+// Encrypt pushes the first block in src through the round and writes the result to dst. Dst and src may point at the
+// same memory.
+func (r round) Encrypt(dst, src []byte) {
+	copy(dst[0:16], src[0:16])
+
+	if r.tracer != nil {
+		r.trace(dst)
+		r.tracer.OnState(r.round, dst[:16])
+		return
+	}
+
+	// Apply the T-Boxes and Tyi Tables to each column of the state matrix.
+	for pos := 0; pos < 16; pos += 4 {
+		stretched := common.ExpandWord(r.constr.TBoxTyiTable[r.round][pos:pos+4], dst[pos:pos+4])
+		common.SquashWords(r.constr.HighXORTable[r.round][2*pos:2*pos+8], stretched, dst[pos:pos+4])
+
+		stretched = common.ExpandWord(r.constr.MBInverseTable[r.round][pos:pos+4], dst[pos:pos+4])
+		common.SquashWords(r.constr.LowXORTable[r.round][2*pos:2*pos+8], stretched, dst[pos:pos+4])
+	}
+}
+
+// Decrypt is not implemented.
+func (r round) Decrypt(_, _ []byte) {}
+
+// trace computes the same thing as Encrypt on the state in dst, one lookup at a time, and reports each lookup to the
+// tracer.
+func (r round) trace(dst []byte) {
+	expand := func(kind string, tables []table.Word, pos int) (out [4][4]byte) {
+		for i := 0; i < 4; i++ {
+			out[i] = tables[i].Get(dst[pos+i])
+			r.tracer.OnLookup(r.round, pos+i, kind, dst[pos+i:pos+i+1], out[i][:])
+		}
+
+		return
+	}
+
+	squash := func(kind string, xorTable [][3]table.Nibble, pos int, words [4][4]byte) {
+		copy(dst[pos:pos+4], words[0][:])
+
+		for i := 1; i < 4; i++ {
+			for j := 0; j < 4; j++ {
+				aPartial := dst[pos+j]&0xf0 | (words[i][j]&0xf0)>>4
+				bPartial := (dst[pos+j]&0x0f)<<4 | words[i][j]&0x0f
+
+				a, b := []byte{xorTable[2*j+0][i-1].Get(aPartial)}, []byte{xorTable[2*j+1][i-1].Get(bPartial)}
+				r.tracer.OnLookup(r.round, pos+j, kind, []byte{aPartial}, a)
+				r.tracer.OnLookup(r.round, pos+j, kind, []byte{bPartial}, b)
+
+				dst[pos+j] = a[0]<<4 | b[0]&0x0f
+			}
+		}
+	}
+
+	for pos := 0; pos < 16; pos += 4 {
+		stretched := expand("TBoxTyi", r.constr.TBoxTyiTable[r.round][pos:pos+4], pos)
+		squash("HighXOR", r.constr.HighXORTable[r.round][2*pos:2*pos+8], pos, stretched)
+
+		stretched = expand("MBInverse", r.constr.MBInverseTable[r.round][pos:pos+4], pos)
+		squash("LowXOR", r.constr.LowXORTable[r.round][2*pos:2*pos+8], pos, stretched)
+	}
+}
@@ -0,0 +1,293 @@
+package chow
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/matrix"
+	"github.com/OpenWhiteBox/primitives/random"
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// watermarkSize is the number of bits in a watermark.
+const watermarkSize = 32
+
+// watermarkSlot returns the round and nibble-wise position of the HighXORTable that carries the given bit of the
+// watermark.
+func watermarkSlot(bit int) (round, position int) {
+	return bit % 9, bit
+}
+
+// watermarkEncoding is the nibble encoding composed into the slot of the given bit when that bit is set to value.
+func watermarkEncoding(rs *random.Source, bit int, value byte) encoding.Nibble {
+	label := make([]byte, 16)
+	label[0], label[1], label[2], label[3] = 'W', 'M', byte(bit), value
+
+	return rs.Shuffle(label)
+}
+
+// embedWatermark embeds id into the construction without changing the function it computes. For each bit of id, a
+// nibble encoding chosen by the value of the bit is composed onto the output of the first XOR gate in that bit's slot,
+// and its inverse onto the input of the second gate.
+func embedWatermark(rs *random.Source, constr *Construction, id uint32) {
+	for bit := 0; bit < watermarkSize; bit++ {
+		round, pos := watermarkSlot(bit)
+		enc := watermarkEncoding(rs, bit, byte(id>>uint(bit))&1)
+
+		constr.HighXORTable[round][pos][0] = encoding.NibbleTable{
+			encoding.IdentityByte{}, enc, constr.HighXORTable[round][pos][0],
+		}
+		constr.HighXORTable[round][pos][1] = encoding.NibbleTable{
+			encoding.ConcatenatedByte{enc, encoding.IdentityByte{}},
+			encoding.IdentityByte{},
+			constr.HighXORTable[round][pos][1],
+		}
+	}
+}
+
+// GenerateWatermarkedEncryptionKeys is GenerateEncryptionKeys, but also embeds the identifier id into the construction.
+// The construction computes the same function as the one returned by GenerateEncryptionKeys with the same key and seed,
+// and id can be recovered from it with Trace and the seed.
+func GenerateWatermarkedEncryptionKeys(key, seed []byte, id uint32, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	out, inputMask, outputMask = GenerateEncryptionKeys(key, seed, opts)
+
+	rs := random.NewSource("Chow Encryption", seed)
+	embedWatermark(&rs, &out, id)
+
+	return
+}
+
+// GenerateWatermarkedDecryptionKeys is GenerateDecryptionKeys, but also embeds the identifier id into the construction.
+// See GenerateWatermarkedEncryptionKeys.
+func GenerateWatermarkedDecryptionKeys(key, seed []byte, id uint32, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	out, inputMask, outputMask = GenerateDecryptionKeys(key, seed, opts)
+
+	rs := random.NewSource("Chow Decryption", seed)
+	embedWatermark(&rs, &out, id)
+
+	return
+}
+
+// Trace recovers the identifier embedded in a serialized, watermarked construction that was generated from seed. The
+// AES key isn't needed. Confidence is the fraction of the identifier's bits that could be read from the construction; a
+// construction that was generated without a watermark, from another seed, or that has been re-randomized has
+// confidence near zero.
+//
+// Tracing needs the tables themselves and the seed, because the watermark is only visible by comparing the tables with
+// the ones the seed generates. Instances that can only be queried should be watermarked with
+// GenerateOracleWatermarkedEncryptionKeys instead.
+func Trace(serialized, seed []byte) (id uint32, confidence float64) {
+	constr, err := Parse(serialized)
+	if err != nil {
+		return 0, 0
+	}
+
+	for _, name := range []string{"Chow Encryption", "Chow Decryption"} {
+		rs := random.NewSource(name, seed)
+		cand, found := traceWith(&rs, &constr)
+
+		if c := float64(found) / watermarkSize; c > confidence {
+			id, confidence = cand, c
+		}
+	}
+
+	return
+}
+
+// traceWith reads the watermark from constr, assuming it was generated from the random source rs. It returns the bits
+// that were found and how many of them there were.
+//
+// The same reference tables work for encryption and decryption instances: both keygens build the HighXORTables with
+// common.NoShift, and the first gate of a chain, which is the only one the watermark touches, never depends on the shift
+// anyway.
+func traceWith(rs *random.Source, constr *Construction) (id uint32, found int) {
+	base := xorTables(rs, common.Inside, common.NoShift)
+
+	for bit := 0; bit < watermarkSize; bit++ {
+		round, pos := watermarkSlot(bit)
+		real := table.SerializeNibble(constr.HighXORTable[round][pos][0])
+
+		for value := byte(0); value < 2; value++ {
+			cand := table.SerializeNibble(encoding.NibbleTable{
+				encoding.IdentityByte{}, watermarkEncoding(rs, bit, value), base[round][pos][0],
+			})
+
+			if bytes.Equal(real, cand) {
+				id |= uint32(value) << uint(bit)
+				found++
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// oracleSlots is the number of bytes in an oracle watermark. Each is carried by one T-Box/Tyi table of the first round.
+const oracleSlots = 4
+
+// oracleVotes is the number of queries that must all disagree with the unwatermarked instance before an entry is taken
+// to carry a byte of an oracle watermark. A single query can hit another slot's entry by chance.
+const oracleVotes = 3
+
+// oracleTries bounds the number of random inputs tried while looking for ones that hit every entry of every slot.
+const oracleTries = 1 << 15
+
+// oracleSlot returns the position of the first-round T-Box/Tyi table that carries the given byte of an oracle watermark,
+// the mask that byte is XORed with to pick the entry that's changed, and the non-zero difference added to that entry.
+func oracleSlot(rs *random.Source, slot int) (pos int, mask byte, delta [4]byte) {
+	label := make([]byte, 16)
+	label[0], label[1], label[2] = 'O', 'W', byte(slot)
+
+	buff := make([]byte, 5)
+	rs.Stream(label).Read(buff)
+
+	copy(delta[:], buff[1:])
+	delta[0] |= 0x01
+
+	return 4 * slot, buff[0], delta
+}
+
+// deviantWord is a word table that agrees with Word on every input except Entry, where Delta is added to its output.
+type deviantWord struct {
+	table.Word
+
+	Entry byte
+	Delta [4]byte
+}
+
+func (dw deviantWord) Get(i byte) (out [4]byte) {
+	out = dw.Word.Get(i)
+
+	if i == dw.Entry {
+		for k := range out {
+			out[k] ^= dw.Delta[k]
+		}
+	}
+
+	return
+}
+
+// embedOracleWatermark embeds id into the construction by changing one entry of a first-round T-Box/Tyi table for each
+// byte of id. The construction then disagrees with AES exactly on the inputs that hit one of those entries.
+func embedOracleWatermark(rs *random.Source, constr *Construction, id uint32) {
+	for slot := 0; slot < oracleSlots; slot++ {
+		pos, mask, delta := oracleSlot(rs, slot)
+		constr.TBoxTyiTable[0][pos] = deviantWord{constr.TBoxTyiTable[0][pos], byte(id>>uint(8*slot)) ^ mask, delta}
+	}
+}
+
+// GenerateOracleWatermarkedEncryptionKeys is GenerateEncryptionKeys, but also embeds the identifier id into the function
+// the construction computes, so that it can be recovered with TraceEncryptionOracle from an instance that can only be
+// queried.
+//
+// The construction is equivalent to the one returned by GenerateEncryptionKeys on all but a few chosen inputs: those
+// whose state, entering the first round, has one of four chosen values in one of four chosen bytes. That's about one
+// input in 64. Unlike the watermark embedded by GenerateWatermarkedEncryptionKeys, it survives re-randomization.
+func GenerateOracleWatermarkedEncryptionKeys(key, seed []byte, id uint32, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	out, inputMask, outputMask = GenerateEncryptionKeys(key, seed, opts)
+
+	rs := random.NewSource("Chow Encryption", seed)
+	embedOracleWatermark(&rs, &out, id)
+
+	return
+}
+
+// GenerateOracleWatermarkedDecryptionKeys is GenerateDecryptionKeys, but also embeds the identifier id into the function
+// the construction computes. See GenerateOracleWatermarkedEncryptionKeys.
+func GenerateOracleWatermarkedDecryptionKeys(key, seed []byte, id uint32, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	out, inputMask, outputMask = GenerateDecryptionKeys(key, seed, opts)
+
+	rs := random.NewSource("Chow Decryption", seed)
+	embedOracleWatermark(&rs, &out, id)
+
+	return
+}
+
+// TraceEncryptionOracle recovers the identifier embedded by GenerateOracleWatermarkedEncryptionKeys, given only the
+// ability to encrypt with the instance, and the key, seed, and options it was generated with. Confidence is the fraction
+// of the identifier's bytes that could be recovered; an instance that was generated without a watermark or from another
+// seed has confidence near zero.
+func TraceEncryptionOracle(oracle cipher.Block, key, seed []byte, opts common.KeyGenerationOpts) (id uint32, confidence float64) {
+	ref, _, _ := GenerateEncryptionKeys(key, seed, opts)
+	ref, _ = Parse(ref.Serialize()) // Serialized tables are much faster to query than generated ones.
+
+	rs := random.NewSource("Chow Encryption", seed)
+	return traceOracle(&rs, oracle.Encrypt, ref.Encrypt, &ref, ref.shiftRows)
+}
+
+// TraceDecryptionOracle recovers the identifier embedded by GenerateOracleWatermarkedDecryptionKeys, given only the
+// ability to decrypt with the instance. See TraceEncryptionOracle.
+func TraceDecryptionOracle(oracle cipher.Block, key, seed []byte, opts common.KeyGenerationOpts) (id uint32, confidence float64) {
+	ref, _, _ := GenerateDecryptionKeys(key, seed, opts)
+	ref, _ = Parse(ref.Serialize()) // Serialized tables are much faster to query than generated ones.
+
+	rs := random.NewSource("Chow Decryption", seed)
+	return traceOracle(&rs, oracle.Decrypt, ref.Decrypt, &ref, ref.unShiftRows)
+}
+
+// traceOracle reads an oracle watermark from query, assuming it was generated from the random source rs. reference is
+// the same direction of ref, the unwatermarked construction with the same key and seed, and shift is the permutation
+// applied to the state before the first round.
+//
+// For each slot, it finds inputs that hit every entry of the slot's table and asks which entry the oracle disagrees with
+// the reference on.
+func traceOracle(rs *random.Source, query, reference func(dst, src []byte), ref *Construction, shift func([]byte)) (id uint32, confidence float64) {
+	positions, masks := [oracleSlots]int{}, [oracleSlots]byte{}
+	for slot := 0; slot < oracleSlots; slot++ {
+		positions[slot], masks[slot], _ = oracleSlot(rs, slot)
+	}
+
+	inputs := [oracleSlots][256][][]byte{}
+	missing := oracleSlots * 256 * oracleVotes
+
+	stream := rs.Stream([]byte("OW Trace"))
+	for try := 0; missing > 0 && try < oracleTries; try++ {
+		in, state := make([]byte, 16), make([]byte, 16)
+		io.ReadFull(stream, in)
+
+		ref.Prologue().Encrypt(state, in)
+		shift(state)
+
+		for slot := 0; slot < oracleSlots; slot++ {
+			if entry := state[positions[slot]]; len(inputs[slot][entry]) < oracleVotes {
+				inputs[slot][entry] = append(inputs[slot][entry], in)
+				missing--
+			}
+		}
+	}
+
+	want, got := make([]byte, 16), make([]byte, 16)
+	found := 0
+
+	for slot := 0; slot < oracleSlots; slot++ {
+		hits, hit := 0, byte(0)
+
+		for entry, ins := range inputs[slot] {
+			votes := 0
+			for _, in := range ins {
+				query(got, in)
+				reference(want, in)
+
+				if !bytes.Equal(got, want) {
+					votes++
+				}
+			}
+
+			if votes == oracleVotes {
+				hits, hit = hits+1, byte(entry)
+			}
+		}
+
+		if hits == 1 {
+			id |= uint32(hit^masks[slot]) << uint(8*slot)
+			found++
+		}
+	}
+
+	return id, float64(found) / oracleSlots
+}
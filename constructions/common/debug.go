@@ -0,0 +1,64 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// States is the unencoded state of AES at each point where a debugger checks a white-box. States[0] is the plaintext,
+// States[r] for 1 <= r <= 9 is the state after MixColumns in round r, before that round's key is added, and States[10]
+// is the ciphertext.
+type States [11][16]byte
+
+// ExpectedStates computes the States of AES with the given key on the given plaintext.
+func ExpectedStates(key, block []byte) (out States) {
+	constr := saes.Construction{key}
+	roundKeys := constr.StretchedKey()
+
+	state := make([]byte, 16)
+	copy(state, block)
+	copy(out[0][:], state)
+
+	constr.AddRoundKey(roundKeys[0], state)
+
+	for round := 1; round < 10; round++ {
+		constr.SubBytes(state)
+		constr.ShiftRows(state)
+		constr.MixColumns(state)
+		copy(out[round][:], state)
+
+		constr.AddRoundKey(roundKeys[round], state)
+	}
+
+	constr.SubBytes(state)
+	constr.ShiftRows(state)
+	constr.AddRoundKey(roundKeys[10], state)
+	copy(out[10][:], state)
+
+	return
+}
+
+// Divergence is the first point where the state decoded from a white-box disagrees with AES. Stage is the index into
+// States and Position is the byte of the state.
+type Divergence struct {
+	Stage, Position   int
+	Expected, Decoded byte
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("State diverges from AES at stage %v, position %v! %02x != %02x", d.Stage, d.Position, d.Expected, d.Decoded)
+}
+
+// Compare returns a *Divergence for the first byte where decoded disagrees with expected, or nil if they agree.
+func Compare(expected, decoded States) error {
+	for stage := range expected {
+		for pos := range expected[stage] {
+			if expected[stage][pos] != decoded[stage][pos] {
+				return &Divergence{stage, pos, expected[stage][pos], decoded[stage][pos]}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+)
+
+// DeviceMask derives the mask that binds a white-box to the device with the given fingerprint. It's a public function of
+// the fingerprint, so the binding only deters naive copying: anyone who knows the fingerprint can compute the mask.
+func DeviceMask(fingerprint []byte) (out [16]byte) {
+	h := sha256.New()
+	h.Write([]byte("Device Binding"))
+	h.Write(fingerprint)
+
+	copy(out[:], h.Sum(nil))
+	return
+}
+
+// DeviceTweak returns what to XOR into the first round key of a white-box so that it cancels the device mask device,
+// when the device mask is added to the white-box's input. The state seen by the first round key is
+// shift(inputMask(input)). DeviceTweak returns all zeros if device is nil.
+func DeviceTweak(device *[16]byte, inputMask *matrix.Matrix, shift func([]byte)) []byte {
+	if device == nil {
+		return make([]byte, 16)
+	}
+
+	out := make([]byte, 16)
+	copy(out, inputMask.Mul(matrix.Row(device[:])))
+	shift(out)
+
+	return out
+}
+
+// BoundBlock evaluates a device-bound white-box on the device with the given fingerprint. It only computes the same
+// function as the white-box it was generated from if Fingerprint is the fingerprint the white-box was bound to. Nothing
+// checks that Fingerprint is really this device's; see DeviceMask.
+type BoundBlock struct {
+	Block       cipher.Block
+	Fingerprint []byte
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (bb BoundBlock) BlockSize() int { return 16 }
+
+// Encrypt adds the device mask to the first block in src and pushes it through the white-box's Encrypt method.
+func (bb BoundBlock) Encrypt(dst, src []byte) {
+	mask := DeviceMask(bb.Fingerprint)
+
+	copy(dst, src[:16])
+	for pos := 0; pos < 16; pos++ {
+		dst[pos] ^= mask[pos]
+	}
+
+	bb.Block.Encrypt(dst, dst)
+}
+
+// Decrypt adds the device mask to the first block in src and pushes it through the white-box's Decrypt method.
+func (bb BoundBlock) Decrypt(dst, src []byte) {
+	mask := DeviceMask(bb.Fingerprint)
+
+	copy(dst, src[:16])
+	for pos := 0; pos < 16; pos++ {
+		dst[pos] ^= mask[pos]
+	}
+
+	bb.Block.Decrypt(dst, dst)
+}
@@ -0,0 +1,30 @@
+package common
+
+// FaultInjector corrupts a construction while it encrypts or decrypts, to simulate a fault attack. Constructions only
+// consult a FaultInjector through the cipher.Block returned by their Faulted method. Round, position, and kind mean the
+// same as they do for a Tracer.
+type FaultInjector interface {
+	// InjectLookup is called after each table lookup and may change out, the lookup's output.
+	InjectLookup(round, position int, kind string, out []byte)
+
+	// InjectState is called at the end of each round and may change the state.
+	InjectState(round int, state []byte)
+}
+
+// Injecting returns a Tracer that hands every lookup and state to f, so a construction's Traced method can be used to
+// implement its Faulted method.
+func Injecting(f FaultInjector) Tracer {
+	return injecting{f}
+}
+
+type injecting struct {
+	f FaultInjector
+}
+
+func (i injecting) OnLookup(round, position int, kind string, _, out []byte) {
+	i.f.InjectLookup(round, position, kind, out)
+}
+
+func (i injecting) OnState(round int, state []byte) {
+	i.f.InjectState(round, state)
+}
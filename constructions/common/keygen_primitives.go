@@ -1,6 +1,7 @@
 package common
 
 import (
+	"github.com/OpenWhiteBox/primitives/encoding"
 	"github.com/OpenWhiteBox/primitives/matrix"
 	"github.com/OpenWhiteBox/primitives/random"
 )
@@ -32,6 +33,30 @@ type SameMasks MaskType
 // MatchingMasks implies a randomly generated input mask and the inverse mask on the output.
 type MatchingMasks struct{}
 
+type EncodingType int
+
+const (
+	NoEncoding        EncodingType = iota
+	AffineEncoding                 // XORs a random constant into each byte, which makes the mask affine.
+	NonlinearEncoding              // Applies a random bijection to each nibble.
+)
+
+// ExternalEncodings puts byte-wise encodings outside of the input and output masks, which are generated according to
+// Masks. Only constructions that call GenerateExternalEncodings support it.
+type ExternalEncodings struct {
+	Masks         KeyGenerationOpts
+	Input, Output EncodingType
+}
+
+// MaskOpts strips any external encodings off of opts, leaving the options GenerateMasks takes.
+func MaskOpts(opts KeyGenerationOpts) KeyGenerationOpts {
+	if ext, ok := opts.(ExternalEncodings); ok {
+		return ext.Masks
+	}
+
+	return opts
+}
+
 // GenerateMasks generates input and output encodings for a white-box AES construction.
 func GenerateMasks(rs *random.Source, opts KeyGenerationOpts, inputMask, outputMask *matrix.Matrix) {
 	switch opts.(type) {
@@ -67,6 +92,49 @@ func generateMask(rs *random.Source, maskType MaskType, surface Surface) matrix.
 	}
 }
 
+// GenerateExternalEncodings generates the byte-wise encodings on the outside of a white-box AES construction. The
+// construction computes output(outputMask(AES(inputMask(input^(-1)(x))))). Both are the identity unless opts is an
+// ExternalEncodings. Each byte's encoding is a pair of nibble encodings, so that it can be merged into nibble tables.
+//
+// The encodings only depend on seed, so the caller of a key generation function gets the same ones by calling this with
+// the same seed and opts.
+func GenerateExternalEncodings(seed []byte, opts KeyGenerationOpts) (input, output encoding.ConcatenatedBlock) {
+	rs := random.NewSource("External Encodings", seed)
+
+	ext, _ := opts.(ExternalEncodings)
+	return generateExternalEncoding(&rs, ext.Input, Inside), generateExternalEncoding(&rs, ext.Output, Outside)
+}
+
+func generateExternalEncoding(rs *random.Source, encType EncodingType, surface Surface) (out encoding.ConcatenatedBlock) {
+	label := make([]byte, 16)
+	label[0], label[1], label[2], label[3] = 'E', 'X', 'T', byte(surface)
+
+	constant := make([]byte, 16)
+	rs.Stream(label).Read(constant)
+
+	for pos := 0; pos < 16; pos++ {
+		switch encType {
+		case NoEncoding:
+			out[pos] = encoding.ConcatenatedByte{encoding.IdentityByte{}, encoding.IdentityByte{}}
+		case AffineEncoding:
+			out[pos] = encoding.ConcatenatedByte{
+				encoding.ByteAdditive(constant[pos] >> 4), encoding.ByteAdditive(constant[pos] & 0x0f),
+			}
+		case NonlinearEncoding:
+			label[4], label[5] = byte(pos), 0
+			left := rs.Shuffle(label)
+			label[5] = 1
+			right := rs.Shuffle(label)
+
+			out[pos] = encoding.ConcatenatedByte{left, right}
+		default:
+			panic("Unrecognized external encoding type!")
+		}
+	}
+
+	return
+}
+
 // Generate byte/word mixing bijections.
 // TODO: Ensure that blocks are full-rank.
 func MixingBijection(rs *random.Source, size, round, position int) matrix.Matrix {
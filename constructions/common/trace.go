@@ -0,0 +1,59 @@
+package common
+
+// Tracer observes a construction while it encrypts or decrypts. Constructions only call a Tracer through the
+// cipher.Block returned by their Traced method; their own Encrypt and Decrypt don't check for one.
+//
+// Round is the round of the construction an event happened in: Round(i) is round i, the Prologue is round -1, and the
+// Epilogue is the round after the last one. The slices passed to a Tracer are only valid during the call. Changes a
+// Tracer makes to out or state are seen by the rest of the evaluation, which is how faults are injected.
+type Tracer interface {
+	// OnLookup is called after each table lookup. Position is the byte-wise position in the state the lookup works
+	// on, or -1 if it works on the whole state, and kind names the table.
+	OnLookup(round, position int, kind string, in, out []byte)
+
+	// OnState is called with the state at the end of each round. Every trace starts with round -1.
+	OnState(round int, state []byte)
+}
+
+// Identity is the Prologue or Epilogue of a construction that doesn't need one. It passes the first block through
+// unchanged, and tells Tracer, if it isn't nil, about it as the state after Round.
+type Identity struct {
+	Round  int
+	Tracer Tracer
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (id Identity) BlockSize() int { return 16 }
+
+// Encrypt copies the first block in src into dst.
+func (id Identity) Encrypt(dst, src []byte) {
+	copy(dst, src[:16])
+
+	if id.Tracer != nil {
+		id.Tracer.OnState(id.Round, dst[:16])
+	}
+}
+
+// Decrypt copies the first block in src into dst.
+func (id Identity) Decrypt(dst, src []byte) {
+	id.Encrypt(dst, src)
+}
+
+// TraceSquashBlocks computes the same thing as SquashBlocks, calling t.OnLookup with the given round and kind after each
+// XOR table it looks up.
+func (nxts NibbleXORTables) TraceSquashBlocks(blocks [16][16]byte, dst []byte, t Tracer, round int, kind string) {
+	copy(dst, blocks[0][:])
+
+	for i := 1; i < 16; i++ {
+		for pos := 0; pos < 16; pos++ {
+			aPartial := dst[pos]&0xf0 | (blocks[i][pos]&0xf0)>>4
+			bPartial := (dst[pos]&0x0f)<<4 | blocks[i][pos]&0x0f
+
+			a, b := []byte{nxts[2*pos+0][i-1].Get(aPartial)}, []byte{nxts[2*pos+1][i-1].Get(bPartial)}
+			t.OnLookup(round, pos, kind, []byte{aPartial}, a)
+			t.OnLookup(round, pos, kind, []byte{bPartial}, b)
+
+			dst[pos] = a[0]<<4 | b[0]&0x0f
+		}
+	}
+}
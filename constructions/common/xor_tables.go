@@ -61,6 +61,37 @@ func (nxts NibbleXORTables) SquashBlocks(blocks [16][16]byte, dst []byte) {
 	}
 }
 
+// ExpandWord expands one word of the state matrix with four byte-to-word tables, like T-Boxes composed with Tyi Tables.
+func ExpandWord(tables []table.Word, word []byte) [4][4]byte {
+	return [4][4]byte{tables[0].Get(word[0]), tables[1].Get(word[1]), tables[2].Get(word[2]), tables[3].Get(word[3])}
+}
+
+// ExpandKeyedWord is ExpandWord, but each table also takes the byte of key in the same position.
+func ExpandKeyedWord(tables []table.DoubleToWord, word, key []byte) [4][4]byte {
+	return [4][4]byte{
+		tables[0].Get([2]byte{word[0], key[0]}),
+		tables[1].Get([2]byte{word[1], key[1]}),
+		tables[2].Get([2]byte{word[2], key[2]}),
+		tables[3].Get([2]byte{word[3], key[3]}),
+	}
+}
+
+// SquashWords squashes an expanded word back into one word with 3 pairwise XORs (calc'd one nibble at a time):
+//
+//	(((a ^ b) ^ c) ^ d)
+func SquashWords(xorTable [][3]table.Nibble, words [4][4]byte, dst []byte) {
+	copy(dst, words[0][:])
+
+	for i := 1; i < 4; i++ {
+		for pos := 0; pos < 4; pos++ {
+			aPartial := dst[pos]&0xf0 | (words[i][pos]&0xf0)>>4
+			bPartial := (dst[pos]&0x0f)<<4 | words[i][pos]&0x0f
+
+			dst[pos] = xorTable[2*pos+0][i-1].Get(aPartial)<<4 | xorTable[2*pos+1][i-1].Get(bPartial)
+		}
+	}
+}
+
 func (nxts NibbleXORTables) Serialize() []byte {
 	dst, base := make([]byte, nxtsSize), 0
 
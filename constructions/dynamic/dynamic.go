@@ -0,0 +1,107 @@
+// Package dynamic implements a dynamic-key white-box AES construction. Unlike the other constructions, no key is baked
+// into the tables: they compute the AES round function on an encoded state and an encoded round key, so the key can be
+// changed at runtime without regenerating the tables.
+//
+// Each instance has per-instance encodings on its round key inputs. The encoded round keys for an instance are produced
+// by the KeyEncoder returned alongside it, from the round keys given by saes.Construction.StretchedKey.
+//
+// The overall layout follows Chow et al.'s construction: input and output masks are computed with Block tables and
+// nibble-wise XOR tables, and the state is carried between rounds under nibble encodings and byte mixing bijections.
+package dynamic
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// EncodedKey is the stretched AES key, with each byte of each round key encoded under the instance's key encodings.
+// Round keys 0 through 9 are stored after ShiftRows has been applied to them.
+type EncodedKey [11][16]byte
+
+type Construction struct {
+	InputMask      [16]table.Block // [position]
+	InputXORTables common.NibbleXORTables
+
+	TBoxTyiTable [9][16]table.DoubleToWord // [round][position]
+	HighXORTable [9][32][3]table.Nibble    // [round][nibble-wise position][gate number]
+
+	TBoxTable   [16]table.DoubleToByte // [position]
+	KeyXORTable [16]table.DoubleToByte // [position]
+
+	OutputMask      [16]table.Block // [position]
+	OutputXORTables common.NibbleXORTables
+}
+
+// BlockSize returns the block size of AES.
+func (constr Construction) BlockSize() int { return 16 }
+
+// Encrypt encrypts the first block in src into dst, under the encoded key. Dst and src may point at the same memory.
+func (constr Construction) Encrypt(dst, src []byte, key *EncodedKey) {
+	copy(dst, src[:constr.BlockSize()])
+
+	// Remove input encoding.
+	stretched := constr.expandBlock(constr.InputMask, dst)
+	constr.InputXORTables.SquashBlocks(stretched, dst)
+
+	for round := 0; round < 9; round++ {
+		constr.shiftRows(dst)
+
+		// Apply the keyed T-Boxes and Tyi Tables to each column of the state matrix.
+		for pos := 0; pos < 16; pos += 4 {
+			stretched := common.ExpandKeyedWord(constr.TBoxTyiTable[round][pos:pos+4], dst[pos:pos+4], key[round][pos:pos+4])
+			common.SquashWords(constr.HighXORTable[round][2*pos:2*pos+8], stretched, dst[pos:pos+4])
+		}
+	}
+
+	constr.shiftRows(dst)
+
+	// Apply the final T-Box, add the last round key, and add the output encoding.
+	for pos := 0; pos < 16; pos++ {
+		dst[pos] = constr.TBoxTable[pos].Get([2]byte{dst[pos], key[9][pos]})
+		dst[pos] = constr.KeyXORTable[pos].Get([2]byte{dst[pos], key[10][pos]})
+	}
+
+	stretched = constr.expandBlock(constr.OutputMask, dst)
+	constr.OutputXORTables.SquashBlocks(stretched, dst)
+}
+
+// Bind returns a cipher.Block that encrypts with the construction under the given encoded key. Decryption is not
+// implemented, and panics.
+func (constr *Construction) Bind(key EncodedKey) cipher.Block {
+	return bound{constr, key}
+}
+
+// bound is a construction paired with an encoded key.
+type bound struct {
+	constr *Construction
+	key    EncodedKey
+}
+
+func (b bound) BlockSize() int { return 16 }
+
+func (b bound) Encrypt(dst, src []byte) { b.constr.Encrypt(dst, src, &b.key) }
+
+// Decrypt is not implemented.
+func (b bound) Decrypt(_, _ []byte) {
+	panic("Dynamic-key constructions can't decrypt!")
+}
+
+// shiftRows permutes the bytes of the first block of block, according to AES' ShiftRows operation.
+func (constr *Construction) shiftRows(block []byte) {
+	copy(block, []byte{
+		block[0], block[5], block[10], block[15], block[4], block[9], block[14], block[3], block[8], block[13], block[2],
+		block[7], block[12], block[1], block[6], block[11],
+	})
+}
+
+// expandBlock expands the entire state matrix into sixteen blocks.
+func (constr *Construction) expandBlock(mask [16]table.Block, block []byte) (out [16][16]byte) {
+	for i := 0; i < 16; i++ {
+		out[i] = mask[i].Get(block[i])
+	}
+
+	return
+}
@@ -0,0 +1,120 @@
+package dynamic
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+
+	test_vectors "github.com/OpenWhiteBox/AES/constructions/test"
+)
+
+var (
+	key   = []byte{72, 101, 108, 108, 111, 32, 87, 111, 114, 108, 100, 33, 33, 33, 33, 33}
+	seed  = []byte{38, 41, 142, 156, 29, 181, 23, 194, 21, 250, 223, 183, 210, 168, 214, 145}
+	input = []byte{99, 83, 224, 140, 9, 96, 225, 4, 205, 112, 183, 81, 186, 202, 208, 231}
+)
+
+func TestUnmaskedEncrypt(t *testing.T) {
+	cand, real := make([]byte, 16), make([]byte, 16)
+
+	// Calculate the candidate output.
+	constr, encoder, _, _ := GenerateEncryptionKeys(seed, common.SameMasks(common.IdentityMask))
+	encodedKey := encoder.EncodeKey(key)
+	constr.Encrypt(cand, input, &encodedKey)
+
+	// Calculate the real output.
+	c, _ := aes.NewCipher(key)
+	c.Encrypt(real, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with result! %x != %x", real, cand)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	// One instance is used with every key in the test vectors.
+	constr, encoder, inputMask, outputMask := GenerateEncryptionKeys(
+		seed, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	for n, vec := range test_vectors.GetAESVectors(testing.Short()) {
+		in, out := make([]byte, 16), make([]byte, 16)
+
+		copy(in, inputInv.Mul(matrix.Row(vec.In))) // Apply input encoding.
+
+		constr.Bind(encoder.EncodeKey(vec.Key)).Encrypt(out, in)
+
+		copy(out, outputInv.Mul(matrix.Row(out))) // Remove output encoding.
+
+		if !bytes.Equal(vec.Out, out) {
+			t.Fatalf("Real disagrees with result in test vector %v! %x != %x", n, vec.Out, out)
+		}
+	}
+}
+
+func TestBoundDecrypt(t *testing.T) {
+	constr, encoder, _, _ := GenerateEncryptionKeys(seed, common.SameMasks(common.IdentityMask))
+	out := make([]byte, 16)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Decrypted with a dynamic-key construction!")
+		}
+	}()
+	constr.Bind(encoder.EncodeKey(key)).Decrypt(out, input)
+}
+
+func TestEncodedKeysDiffer(t *testing.T) {
+	_, encoder1, _, _ := GenerateEncryptionKeys(seed, common.SameMasks(common.IdentityMask))
+	_, encoder2, _, _ := GenerateEncryptionKeys(key, common.SameMasks(common.IdentityMask))
+
+	if encoder1.EncodeKey(key) == encoder2.EncodeKey(key) {
+		t.Fatalf("Two instances encoded a key the same way!")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the persistence test in short mode!")
+	}
+
+	constr1, encoder, _, _ := GenerateEncryptionKeys(seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	encodedKey := encoder.EncodeKey(key)
+
+	serialized := constr1.Serialize()
+	constr2, err := Parse(serialized)
+
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	cand1, cand2 := make([]byte, 16), make([]byte, 16)
+
+	constr1.Encrypt(cand1, input, &encodedKey)
+	constr2.Encrypt(cand2, input, &encodedKey)
+
+	if !bytes.Equal(cand1, cand2) {
+		t.Fatalf("Real disagrees with parsed! %x != %x", cand1, cand2)
+	}
+}
+
+// A "Live" Encryption is one based on table abstractions, so many computations are performed on-demand.
+func BenchmarkLiveEncrypt(b *testing.B) {
+	constr, encoder, _, _ := GenerateEncryptionKeys(seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	encodedKey := encoder.EncodeKey(key)
+
+	out := make([]byte, 16)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		constr.Encrypt(out, input, &encodedKey)
+	}
+}
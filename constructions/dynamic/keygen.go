@@ -0,0 +1,116 @@
+package dynamic
+
+import (
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/matrix"
+	"github.com/OpenWhiteBox/primitives/random"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// KeyEncoder produces encoded round keys for the instance generated from the same seed. It is private output of key
+// generation and should be kept with whatever manages the AES keys, not shipped with the instance.
+type KeyEncoder struct {
+	Seed []byte
+}
+
+// EncodeKey stretches the AES key `key` and encodes every round key byte for use with the instance.
+func (ke KeyEncoder) EncodeKey(key []byte) (out EncodedKey) {
+	rs := random.NewSource("Dynamic Encryption", ke.Seed)
+
+	constr := saes.Construction{key}
+	roundKeys := constr.StretchedKey()
+
+	// Apply ShiftRows to round keys 0 to 9.
+	for k := 0; k < 10; k++ {
+		constr.ShiftRows(roundKeys[k])
+	}
+
+	for round := 0; round < 11; round++ {
+		for pos := 0; pos < 16; pos++ {
+			out[round][pos] = keyEncoding(&rs, round, pos).Encode(roundKeys[round][pos])
+		}
+	}
+
+	return
+}
+
+// GenerateEncryptionKeys creates a key-less white-boxed version of AES for encryption, with any non-determinism generated
+// by seed. Opts specifies what type of input and output masks we put on the construction and should be in
+// common.{IndependentMasks, SameMasks, MatchingMasks}. The returned KeyEncoder converts AES keys into encoded keys for
+// the construction.
+func GenerateEncryptionKeys(seed []byte, opts common.KeyGenerationOpts) (out Construction, encoder KeyEncoder, inputMask, outputMask matrix.Matrix) {
+	rs := random.NewSource("Dynamic Encryption", seed)
+	encoder.Seed = append([]byte{}, seed...)
+
+	constr := saes.Construction{}
+
+	// Generate input and output encodings.
+	common.GenerateMasks(&rs, opts, &inputMask, &outputMask)
+
+	// Generate the Input Mask slices and XOR tables.
+	for pos := 0; pos < 16; pos++ {
+		out.InputMask[pos] = encoding.BlockTable{
+			encoding.IdentityByte{},
+			blockMaskEncoding(&rs, pos, common.Inside, common.ShiftRows),
+			common.BlockMatrix{Linear: inputMask, Position: pos},
+		}
+	}
+
+	out.InputXORTables = common.BlockNibbleXORTables(
+		maskEncoding(&rs, common.Inside),
+		xorEncoding(&rs, 10, common.Inside),
+		roundEncoding(&rs, -1, common.ShiftRows),
+	)
+
+	// Generate round material.
+	for round := 0; round < 9; round++ {
+		for pos := 0; pos < 16; pos++ {
+			out.TBoxTyiTable[round][pos] = encoding.DoubleToWordTable{
+				encoding.ConcatenatedDouble{stateEncoding(&rs, round-1, pos), keyEncoding(&rs, round, pos)},
+				encoding.ComposedWords{
+					encoding.ConcatenatedWord{
+						encoding.NewByteLinear(common.MixingBijection(&rs, 8, round, common.ShiftRows(pos/4*4+0))),
+						encoding.NewByteLinear(common.MixingBijection(&rs, 8, round, common.ShiftRows(pos/4*4+1))),
+						encoding.NewByteLinear(common.MixingBijection(&rs, 8, round, common.ShiftRows(pos/4*4+2))),
+						encoding.NewByteLinear(common.MixingBijection(&rs, 8, round, common.ShiftRows(pos/4*4+3))),
+					},
+					wordTyiEncoding(&rs, round, pos),
+				},
+				keyedTBoxTyi{constr, uint(pos % 4)},
+			}
+		}
+	}
+
+	out.HighXORTable = xorTables(&rs)
+
+	// Generate the final T-Box, the last key addition, and the Output Mask slices and XOR tables.
+	for pos := 0; pos < 16; pos++ {
+		out.TBoxTable[pos] = encoding.DoubleToByteTable{
+			encoding.ConcatenatedDouble{stateEncoding(&rs, 8, pos), keyEncoding(&rs, 9, pos)},
+			finalEncoding(&rs, pos, common.Inside),
+			keyedTBox{constr},
+		}
+
+		out.KeyXORTable[pos] = encoding.DoubleToByteTable{
+			encoding.ConcatenatedDouble{finalEncoding(&rs, pos, common.Inside), keyEncoding(&rs, 10, pos)},
+			finalEncoding(&rs, pos, common.Outside),
+			common.ByteXORTable{},
+		}
+
+		out.OutputMask[pos] = encoding.BlockTable{
+			finalEncoding(&rs, pos, common.Outside),
+			blockMaskEncoding(&rs, pos, common.Outside, common.NoShift),
+			common.BlockMatrix{Linear: outputMask, Position: pos},
+		}
+	}
+
+	out.OutputXORTables = common.BlockNibbleXORTables(
+		maskEncoding(&rs, common.Outside),
+		xorEncoding(&rs, 10, common.Outside),
+		func(position int) encoding.Nibble { return encoding.IdentityByte{} },
+	)
+
+	return
+}
@@ -0,0 +1,27 @@
+package dynamic
+
+import (
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// keyedTBoxTyi computes the SubBytes and AddRoundKey steps on a state byte and a round key byte, followed by the Tyi
+// Table for the given column. It implements table.DoubleToWord.
+type keyedTBoxTyi struct {
+	Constr saes.Construction
+	Column uint
+}
+
+func (kt keyedTBoxTyi) Get(i [2]byte) [4]byte {
+	return common.TyiTable(kt.Column).Get(kt.Constr.SubByte(i[0] ^ i[1]))
+}
+
+// keyedTBox computes the SubBytes and AddRoundKey steps on a state byte and a round key byte. It implements
+// table.DoubleToByte.
+type keyedTBox struct {
+	Constr saes.Construction
+}
+
+func (kt keyedTBox) Get(i [2]byte) byte {
+	return kt.Constr.SubByte(i[0] ^ i[1])
+}
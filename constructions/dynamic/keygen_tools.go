@@ -0,0 +1,186 @@
+package dynamic
+
+import (
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/random"
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// maskEncoding produces encodings for the outputs of the InputMask and OutputMask. All randomness is derived from the
+// random source; surface is common.Inside if these will be the masks between InputMask and InputXORTables or
+// common.Outside if they'll be between OutputMask and OutputXORTables.
+//
+// See constructions/common/keygen_tools.go for information on the function returned.
+func maskEncoding(rs *random.Source, surface common.Surface) func(int, int) encoding.Nibble {
+	return func(position, subPosition int) encoding.Nibble {
+		label := make([]byte, 16)
+		label[0], label[1], label[2], label[3], label[4] = 'M', 'E', byte(position), byte(subPosition), byte(surface)
+
+		return rs.Shuffle(label)
+	}
+}
+
+// xorEncoding produces encodings for intermediate values of XOR tables. All randomness is derived from the random
+// source. If round < 10, they are the encodings of the HighXORTable in the given round. If round = 10, they are the
+// encodings of InputXORTables (surface = common.Inside) or OutputXORTables (surface = common.Outside).
+//
+// See constructions/common/keygen_tools.go for information on the function returned.
+func xorEncoding(rs *random.Source, round int, surface common.Surface) func(int, int) encoding.Nibble {
+	return func(position, gate int) encoding.Nibble {
+		label := make([]byte, 16)
+		label[0], label[1], label[2], label[3], label[4] = 'X', byte(round), byte(position), byte(gate), byte(surface)
+
+		return rs.Shuffle(label)
+	}
+}
+
+// roundEncoding produces encodings for the output of a series of XOR tables / the state input of a TBoxTyiTable or
+// TBoxTable. All randomness is derived from the random source; shift is the permutation that will be applied to the
+// state matrix between the output of the XOR tables and the input of the next, or noshift if this is an input encoding.
+//
+// See constructions/common/keygen_tools.go for information on the function returned.
+func roundEncoding(rs *random.Source, round int, shift func(int) int) func(int) encoding.Nibble {
+	return func(position int) encoding.Nibble {
+		position = 2*shift(position/2) + position%2
+
+		label := make([]byte, 16)
+		label[0], label[1], label[2] = 'R', byte(round), byte(position)
+
+		return rs.Shuffle(label)
+	}
+}
+
+// stateEncoding is the encoding on the state input of a TBoxTyiTable or TBoxTable in the round after the given one: a
+// byte mixing bijection followed by the round encoding.
+func stateEncoding(rs *random.Source, round, position int) encoding.Byte {
+	return encoding.ComposedBytes{
+		encoding.NewByteLinear(common.MixingBijection(rs, 8, round, position)),
+		encoding.ConcatenatedByte{
+			roundEncoding(rs, round, common.NoShift)(2*position + 0),
+			roundEncoding(rs, round, common.NoShift)(2*position + 1),
+		},
+	}
+}
+
+// keyEncoding is the per-instance encoding on the given byte of the given round key. It is all that's needed to
+// produce encoded round keys for an instance.
+func keyEncoding(rs *random.Source, round, position int) encoding.Byte {
+	label := make([]byte, 16)
+	label[0], label[1], label[2], label[3] = 'K', 'L', byte(round), byte(position)
+
+	nibble := func(sub int) encoding.Nibble {
+		label := make([]byte, 16)
+		label[0], label[1], label[2], label[3], label[4] = 'K', 'N', byte(round), byte(position), byte(sub)
+
+		return rs.Shuffle(label)
+	}
+
+	return encoding.ComposedBytes{
+		encoding.NewByteLinear(rs.Matrix(label, 8)),
+		encoding.ConcatenatedByte{nibble(0), nibble(1)},
+	}
+}
+
+// tyiEncoding encodes the output of a keyed T-Box/Tyi Table / the input of a HighXORTable.
+//
+// All randomness is derived from the random source; round is the current round; position is the byte-wise position in
+// the state matrix being stretched; subPosition is the nibble-wise position in the Word table's output.
+func tyiEncoding(rs *random.Source, round, position, subPosition int) encoding.Nibble {
+	label := make([]byte, 16)
+	label[0], label[1], label[2], label[3] = 'T', byte(round), byte(position), byte(subPosition)
+
+	return rs.Shuffle(label)
+}
+
+// wordTyiEncoding concatenates all the Tyi encodings for the full output of a TBoxTyiTable.
+func wordTyiEncoding(rs *random.Source, round, position int) encoding.Word {
+	out := encoding.ConcatenatedWord{}
+
+	for i := 0; i < 4; i++ {
+		out[i] = encoding.ConcatenatedByte{
+			tyiEncoding(rs, round, position, 2*i+0),
+			tyiEncoding(rs, round, position, 2*i+1),
+		}
+	}
+
+	return out
+}
+
+// finalEncoding encodes the output of a TBoxTable / the state input of a KeyXORTable (surface = common.Inside), or
+// the output of a KeyXORTable / the input of an OutputMask table (surface = common.Outside).
+func finalEncoding(rs *random.Source, position int, surface common.Surface) encoding.Byte {
+	nibble := func(sub int) encoding.Nibble {
+		label := make([]byte, 16)
+		label[0], label[1], label[2], label[3] = 'F', byte(position), byte(sub), byte(surface)
+
+		return rs.Shuffle(label)
+	}
+
+	return encoding.ComposedBytes{
+		encoding.NewByteLinear(common.MixingBijection(rs, 8, 9+int(surface), position)),
+		encoding.ConcatenatedByte{nibble(0), nibble(1)},
+	}
+}
+
+// blockMaskEncoding concatenates all the mask encodings for InputMask or OutputMask into a block encoding, so that it
+// can easily be put on the output of one of the Block tables.
+//
+// position is the index of the Block table and shift is the permutation that will be applied between this round and the
+// next or noshift if this is an input encoding; the other parameters are explained in maskEncoding documentation.
+func blockMaskEncoding(rs *random.Source, position int, surface common.Surface, shift func(int) int) encoding.Block {
+	out := encoding.ConcatenatedBlock{}
+
+	for i := 0; i < 16; i++ {
+		out[i] = encoding.ConcatenatedByte{
+			maskEncoding(rs, surface)(position, 2*i+0),
+			maskEncoding(rs, surface)(position, 2*i+1),
+		}
+
+		if surface == common.Inside {
+			out[i] = encoding.ComposedBytes{
+				encoding.NewByteLinear(common.MixingBijection(rs, 8, -1, shift(i))),
+				out[i],
+			}
+		}
+	}
+
+	return out
+}
+
+// xorTables generates the XOR Tables for squashing the result of the keyed T-Box/Tyi Tables.
+func xorTables(rs *random.Source) (out [9][32][3]table.Nibble) {
+	for round := 0; round < 9; round++ {
+		for pos := 0; pos < 32; pos++ {
+			out[round][pos][0] = encoding.NibbleTable{
+				encoding.ConcatenatedByte{
+					tyiEncoding(rs, round, pos/8*4+0, pos%8),
+					tyiEncoding(rs, round, pos/8*4+1, pos%8),
+				},
+				xorEncoding(rs, round, common.Inside)(pos, 0),
+				common.NibbleXORTable{},
+			}
+
+			out[round][pos][1] = encoding.NibbleTable{
+				encoding.ConcatenatedByte{
+					xorEncoding(rs, round, common.Inside)(pos, 0),
+					tyiEncoding(rs, round, pos/8*4+2, pos%8),
+				},
+				xorEncoding(rs, round, common.Inside)(pos, 1),
+				common.NibbleXORTable{},
+			}
+
+			out[round][pos][2] = encoding.NibbleTable{
+				encoding.ConcatenatedByte{
+					xorEncoding(rs, round, common.Inside)(pos, 1),
+					tyiEncoding(rs, round, pos/8*4+3, pos%8),
+				},
+				roundEncoding(rs, round, common.ShiftRows)(pos),
+				common.NibbleXORTable{},
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,99 @@
+package dynamic
+
+import (
+	"errors"
+
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+const (
+	fullSize = 40210432
+
+	keyedTableSize = 65536 * 4
+	finalTableSize = 65536
+	xorTableSize   = 256 / 2
+)
+
+// Serialize serializes a white-box construction into a byte slice.
+func (constr *Construction) Serialize() []byte {
+	out, base := make([]byte, fullSize), 0
+
+	// Input Mask
+	base += common.SerializeBlockMatrix(out[base:], constr.InputMask, constr.InputXORTables)
+
+	// Rounds
+	for _, round := range constr.TBoxTyiTable {
+		for _, tbox := range round {
+			base += copy(out[base:], table.SerializeDoubleToWord(tbox))
+		}
+	}
+
+	for _, round := range constr.HighXORTable {
+		for _, pos := range round {
+			for _, gate := range pos {
+				base += copy(out[base:], table.SerializeNibble(gate))
+			}
+		}
+	}
+
+	// Final round
+	for _, tbox := range constr.TBoxTable {
+		base += copy(out[base:], table.SerializeDoubleToByte(tbox))
+	}
+
+	for _, xor := range constr.KeyXORTable {
+		base += copy(out[base:], table.SerializeDoubleToByte(xor))
+	}
+
+	// Output Mask
+	common.SerializeBlockMatrix(out[base:], constr.OutputMask, constr.OutputXORTables)
+
+	return out
+}
+
+// Parse parses a byte array into a white-box construction. It returns an error if the byte array isn't long enough.
+func Parse(in []byte) (constr Construction, err error) {
+	if len(in) != fullSize {
+		return constr, errors.New("Parsing the key failed!")
+	}
+
+	var rest []byte
+
+	constr.InputMask, constr.InputXORTables, rest = common.ParseBlockNibbleMatrix(in)
+
+	for i := range constr.TBoxTyiTable {
+		for j := range constr.TBoxTyiTable[i] {
+			constr.TBoxTyiTable[i][j] = table.ParsedDoubleToWord(rest[:keyedTableSize])
+			rest = rest[keyedTableSize:]
+		}
+	}
+
+	for i := range constr.HighXORTable {
+		for j := range constr.HighXORTable[i] {
+			for k := range constr.HighXORTable[i][j] {
+				constr.HighXORTable[i][j][k] = table.ParsedNibble(rest[:xorTableSize])
+				rest = rest[xorTableSize:]
+			}
+		}
+	}
+
+	for i := range constr.TBoxTable {
+		constr.TBoxTable[i] = table.ParsedDoubleToByte(rest[:finalTableSize])
+		rest = rest[finalTableSize:]
+	}
+
+	for i := range constr.KeyXORTable {
+		constr.KeyXORTable[i] = table.ParsedDoubleToByte(rest[:finalTableSize])
+		rest = rest[finalTableSize:]
+	}
+
+	constr.OutputMask, constr.OutputXORTables, rest = common.ParseBlockNibbleMatrix(rest)
+
+	if rest == nil {
+		err = errors.New("Parsing the key failed!")
+	}
+
+	return
+}
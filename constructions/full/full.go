@@ -7,6 +7,8 @@ package full
 import (
 	"github.com/OpenWhiteBox/primitives/encoding"
 	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
 )
 
 // blockAffine is a modification of encoding.BlockAffine that allows non-bijective transformations.
@@ -80,18 +82,21 @@ func (constr Construction) BlockSize() int { return 16 }
 
 // Encrypt encrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Encrypt(dst, src []byte) {
-	state := src[:16]
+	constr.encrypt(dst, src, nil)
+}
+
+// encrypt implements Encrypt. If t isn't nil, it's told about every layer of AND gates and the state after every round.
+func (constr *Construction) encrypt(dst, src []byte, t common.Tracer) {
+	state := make([]byte, 64)
+	copy(state, src[:16])
 
-	for i, m := range constr[:len(constr)-1] {
-		temp := m.transform(state)
-		state = make([]byte, stateSize[i%4])
+	common.Identity{-1, t}.Encrypt(state, state)
 
-		cs := compressSize[i%4]
-		compress(state[:cs], temp[:2*cs])
-		copy(state[cs:], temp[2*cs:])
+	for round := 0; round < 10; round++ {
+		layers{constr, 4 * round, 4*round + 4, t}.Encrypt(state, state)
 	}
 
-	state = constr[40].transform(state)
+	layers{constr, 40, 41, t}.Encrypt(state, state)
 	copy(dst[:16], state[:16])
 }
 
@@ -2,8 +2,13 @@ package full
 
 import (
 	"bytes"
+	"crypto/rand"
+	"reflect"
 	"testing"
 
+	"github.com/OpenWhiteBox/AES/constructions/internal/tracetest"
+	"github.com/OpenWhiteBox/AES/constructions/network"
+
 	test_vectors "github.com/OpenWhiteBox/AES/constructions/test"
 )
 
@@ -53,3 +58,80 @@ func TestPersistence(t *testing.T) {
 		t.Fatalf("Real disagrees with parsed! %x != %x", cand1, cand2)
 	}
 }
+
+func TestRerandomize(t *testing.T) {
+	constr1, _, _ := GenerateKeys(key, seed)
+	constr2 := Rerandomize(constr1, key)
+
+	for i := range constr1 {
+		if reflect.DeepEqual(constr1[i], constr2[i]) {
+			t.Fatalf("Rerandomize didn't change affine layer %v!", i)
+		}
+	}
+
+	in, cand1, cand2 := make([]byte, 16), make([]byte, 16), make([]byte, 16)
+
+	for i := 0; i < 16; i++ {
+		rand.Read(in)
+
+		constr1.Encrypt(cand1, in)
+		constr2.Encrypt(cand2, in)
+
+		if !bytes.Equal(cand1, cand2) {
+			t.Fatalf("Real disagrees with rerandomized on %x! %x != %x", in, cand1, cand2)
+		}
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	constr, _, _ := GenerateKeys(key, seed)
+	n, err := network.Parse(constr.Network().Serialize())
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+
+	constr.Encrypt(real, input)
+	n.Encrypt(cand, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with network! %x != %x", real, cand)
+	}
+}
+
+func TestTraced(t *testing.T) {
+	constr, _, _ := GenerateKeys(key, seed)
+	tracer := &tracetest.Counter{}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+	constr.Encrypt(real, input)
+	constr.Traced(tracer).Encrypt(cand, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with result! %x != %x", real, cand)
+	} else if tracer.Lookups != 40 {
+		t.Fatalf("Tracer saw the wrong number of lookups! %v != 40", tracer.Lookups)
+	} else if len(tracer.Rounds) != 12 || tracer.Rounds[0] != -1 || tracer.Rounds[11] != 10 {
+		t.Fatalf("Tracer saw the state after the wrong rounds! %v", tracer.Rounds)
+	} else if !bytes.Equal(tracer.Last[:16], cand) {
+		t.Fatalf("Tracer's last state isn't the output! %x != %x", tracer.Last, cand)
+	}
+}
+
+func TestRounds(t *testing.T) {
+	constr, _, _ := GenerateKeys(key, seed)
+
+	real, cand := make([]byte, 16), make([]byte, 64)
+	constr.Encrypt(real, input)
+
+	constr.Prologue().Encrypt(cand, input)
+	for round := 0; round < 10; round++ {
+		constr.Round(round).Encrypt(cand, cand)
+	}
+	constr.Epilogue().Encrypt(cand, cand)
+
+	if !bytes.Equal(real, cand[:16]) {
+		t.Fatalf("Real disagrees with composed rounds! %x != %x", real, cand[:16])
+	}
+}
@@ -140,6 +140,14 @@ func GenerateKeys(key, seed []byte) (out Construction, inputMask, outputMask enc
 	}).compose(out[40])
 
 	// Sample self-equivalences of the S-box layer and mix them into adjacent affine layers.
+	mixSelfEquivalences(&rs, &out)
+
+	return out, input.BlockAffine(), output.BlockAffine()
+}
+
+// mixSelfEquivalences samples a self-equivalence of each S-box layer and mixes it into the adjacent affine layers. The
+// function computed by the construction is unchanged.
+func mixSelfEquivalences(rs *random.Source, out *Construction) {
 	label := make([]byte, 16)
 	copy(label, []byte("Self-Eq"))
 	r := rs.Stream(label)
@@ -149,6 +157,14 @@ func GenerateKeys(key, seed []byte) (out Construction, inputMask, outputMask enc
 		out[i] = a.compose(out[i])
 		out[i+1] = out[i+1].compose(bInv)
 	}
+}
 
-	return out, input.BlockAffine(), output.BlockAffine()
+// Rerandomize mixes fresh self-equivalences, generated by `seed`, into an existing construction. The returned
+// construction computes the same function with the same external masks, but shares no affine layers with the original.
+// The AES key isn't needed.
+func Rerandomize(constr Construction, seed []byte) Construction {
+	rs := random.NewSource("Full Rerandomization", seed)
+	mixSelfEquivalences(&rs, &constr)
+
+	return constr
 }
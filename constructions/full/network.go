@@ -0,0 +1,37 @@
+package full
+
+import (
+	"github.com/OpenWhiteBox/AES/constructions/network"
+)
+
+// Network converts the construction into a table network. The decomposed S-boxes become AND layers; the construction
+// has no lookup tables.
+func (constr *Construction) Network() *network.Network {
+	n := network.New()
+
+	affine := func(i int) int {
+		return n.AddAffine(network.AffineLayer{Linear: constr[i].linear, Constant: constr[i].constant})
+	}
+
+	state := n.Input()
+
+	for i := 0; i < len(constr)-1; i++ {
+		n.At("Affine", i/4, -1)
+		temp := n.Transform(affine(i), state)
+
+		n.At("AND", i/4, -1)
+		cs := compressSize[i%4]
+		compressed := n.AND(n.Slice(temp, 0, 2*cs), cs)
+
+		if rest := stateSize[i%4] - cs; rest > 0 {
+			state = n.Concat(compressed, n.Slice(temp, 2*cs, rest))
+		} else {
+			state = compressed
+		}
+	}
+
+	n.At("Affine", 10, -1)
+	n.Output = n.Transform(affine(40), state)
+
+	return n
+}
@@ -0,0 +1,74 @@
+package full
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// Round returns round i of the construction, for 0 <= i < 10: four affine layers, each followed by a layer of AND gates.
+// The input mask is part of Round(0).
+//
+// The S-boxes are decomposed across rounds, so the state between rounds isn't 16 bytes wide; each round's BlockSize is
+// the width of its input. A construction is its Prologue, then Round(0) through Round(9), then its Epilogue.
+func (constr *Construction) Round(i int) cipher.Block {
+	return layers{constr, 4 * i, 4*i + 4, nil}
+}
+
+// Prologue returns the part of the construction before the first round. The input mask is part of Round(0), so it's
+// the identity. See Round.
+func (constr *Construction) Prologue() cipher.Block {
+	return common.Identity{-1, nil}
+}
+
+// Epilogue returns the part of the construction after the last round: the affine layer that finishes the last S-box
+// and adds the output mask. See Round.
+func (constr *Construction) Epilogue() cipher.Block {
+	return layers{constr, 40, 41, nil}
+}
+
+// layers is a contiguous run of a construction's layers. Every layer except the 41st is followed by AND gates. If
+// tracer isn't nil, it's told about each layer of AND gates and the state at the end of the run, which must be a whole
+// round or the epilogue.
+type layers struct {
+	constr     *Construction
+	start, end int
+
+	tracer common.Tracer
+}
+
+// BlockSize returns the width of the input to the first layer.
+func (l layers) BlockSize() int { return len(l.constr[l.start].linear[0]) }
+
+// Encrypt pushes the input in src through the layers and writes the output to dst. Dst and src may point at the same
+// memory, if it's big enough to hold both.
+func (l layers) Encrypt(dst, src []byte) {
+	state := append([]byte{}, src[:l.BlockSize()]...)
+
+	for i := l.start; i < l.end; i++ {
+		temp := l.constr[i].transform(state)
+		if i == 40 {
+			state = temp
+			break
+		}
+
+		state = make([]byte, stateSize[i%4])
+
+		cs := compressSize[i%4]
+		compress(state[:cs], temp[:2*cs])
+		copy(state[cs:], temp[2*cs:])
+
+		if l.tracer != nil {
+			l.tracer.OnLookup(i/4, -1, "AND", temp[:2*cs], state[:cs])
+		}
+	}
+
+	if l.tracer != nil {
+		l.tracer.OnState(l.start/4, state)
+	}
+
+	copy(dst, state)
+}
+
+// Decrypt is not implemented.
+func (l layers) Decrypt(_, _ []byte) {}
@@ -0,0 +1,17 @@
+// Package tracetest has helpers for testing the constructions' tracers.
+package tracetest
+
+// Counter is a common.Tracer. It counts lookups and remembers which rounds it saw the state after, and the last state
+// it saw.
+type Counter struct {
+	Lookups int
+	Rounds  []int
+	Last    []byte
+}
+
+func (c *Counter) OnLookup(_, _ int, _ string, _, _ []byte) { c.Lookups++ }
+
+func (c *Counter) OnState(round int, state []byte) {
+	c.Rounds = append(c.Rounds, round)
+	c.Last = append([]byte{}, state...)
+}
@@ -0,0 +1,88 @@
+package network
+
+// Stats summarizes the size and cost of a network.
+type Stats struct {
+	Nodes   int // Number of nodes.
+	Tables  int // Number of distinct lookup tables.
+	Affines int // Number of distinct affine layers.
+
+	Memory int // Bytes needed to store every table and affine layer.
+
+	Lookups    int // Table lookups per block.
+	XORs       int // XOR gates per block, not counting XOR tables.
+	ANDs       int // AND layers per block.
+	Transforms int // Affine transformations per block.
+}
+
+// Stats counts the tables, memory, and operations in the network.
+func (n *Network) Stats() (out Stats) {
+	out.Nodes, out.Tables, out.Affines = len(n.Nodes), len(n.Tables), len(n.Affines)
+
+	for _, t := range n.Tables {
+		out.Memory += len(t.Data)
+	}
+
+	for _, a := range n.Affines {
+		out.Memory += len(a.Constant)
+		for _, row := range a.Linear {
+			out.Memory += len(row)
+		}
+	}
+
+	for _, node := range n.Nodes {
+		switch node.Op {
+		case Lookup:
+			out.Lookups++
+		case XOR:
+			out.XORs++
+		case AND:
+			out.ANDs++
+		case Affine:
+			out.Transforms++
+		}
+	}
+
+	return
+}
+
+// Rounds returns, for each round, the number of table lookups tagged with it. The Prologue's lookups are counted under
+// -1.
+func (n *Network) Rounds() map[int]int {
+	out := make(map[int]int)
+
+	for _, node := range n.Nodes {
+		if node.Op == Lookup {
+			out[node.Round]++
+		}
+	}
+
+	return out
+}
+
+// Widths computes the width in bytes of every node's output, without evaluating the network.
+func (n *Network) Widths() []int {
+	out := make([]int, len(n.Nodes))
+
+	for i, node := range n.Nodes {
+		switch node.Op {
+		case Input:
+			out[i] = 16
+		case Slice, AND:
+			out[i] = node.Width
+		case Concat:
+			for _, src := range node.Inputs {
+				out[i] += out[src]
+			}
+		case Nibble, Join:
+			out[i] = 1
+		case Lookup:
+			out[i] = n.Tables[node.Index].OutWidth
+		case XOR:
+			out[i] = out[node.Inputs[0]]
+		case Affine:
+			out[i] = len(n.Affines[node.Index].Linear) / 8
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,145 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Filter selects the part of a network to render. Round -1 selects the Prologue, and Round AllRounds or a negative
+// Column selects every round or column. Nodes that aren't in any column, like a layer that transforms the whole state,
+// are in every column.
+type Filter struct {
+	Round, Column int
+}
+
+// AllRounds is the Round of a Filter that selects every round.
+const AllRounds = -2
+
+// All renders the whole network.
+var All = Filter{AllRounds, -1}
+
+func (f Filter) match(node Node) bool {
+	if f.Round != AllRounds && node.Round != f.Round {
+		return false
+	} else if f.Column >= 0 && node.Position >= 0 && node.Position/4 != f.Column {
+		return false
+	}
+
+	return true
+}
+
+// visible returns whether a node is drawn. Slices, concatenations, and nibble operations only route data, so they're
+// collapsed into the edges between the nodes they connect.
+func visible(op Op) bool {
+	return op == Input || op == Lookup || op == XOR || op == AND || op == Affine
+}
+
+// sources computes, for each byte of each node's output, the visible nodes it comes from.
+func (n *Network) sources() [][][]int {
+	widths := n.Widths()
+	out := make([][][]int, len(n.Nodes))
+
+	for i, node := range n.Nodes {
+		out[i] = make([][]int, widths[i])
+
+		switch {
+		case visible(node.Op):
+			for j := range out[i] {
+				out[i][j] = []int{i}
+			}
+		case node.Op == Slice:
+			copy(out[i], out[node.Inputs[0]][node.Offset:])
+		case node.Op == Concat:
+			out[i] = out[i][:0]
+			for _, src := range node.Inputs {
+				out[i] = append(out[i], out[src]...)
+			}
+		case node.Op == Nibble:
+			out[i][0] = out[node.Inputs[0]][0]
+		case node.Op == Join:
+			out[i][0] = union(out[node.Inputs[0]][0], out[node.Inputs[1]][0])
+		}
+	}
+
+	return out
+}
+
+// union merges two lists of node indices, dropping duplicates.
+func union(a, b []int) []int {
+	set := make(map[int]bool)
+	for _, i := range append(append([]int{}, a...), b...) {
+		set[i] = true
+	}
+
+	out := make([]int, 0, len(set))
+	for i := range set {
+		out = append(out, i)
+	}
+	sort.Ints(out)
+
+	return out
+}
+
+// label describes a node by its operation and where it came from in the construction.
+func label(node Node) string {
+	out := node.Name
+	if out == "" {
+		out = node.Op.String()
+	}
+
+	out += fmt.Sprintf("\\nround %v", node.Round)
+	if node.Position >= 0 {
+		out += fmt.Sprintf("\\nposition %v", node.Position)
+	}
+	if node.Gate >= 0 {
+		out += fmt.Sprintf("\\ngate %v", node.Gate)
+	}
+
+	return out
+}
+
+var shapes = map[Op]string{Input: "invhouse", Lookup: "box", XOR: "circle", AND: "diamond", Affine: "parallelogram"}
+
+// WriteDOT writes the part of the network selected by f to w as a Graphviz graph. Only lookups, XOR and AND gates, and
+// affine layers are drawn; the slicing and concatenation between them becomes edges.
+func (n *Network) WriteDOT(w io.Writer, f Filter) error {
+	srcs := n.sources()
+	buff := &bytes.Buffer{}
+
+	fmt.Fprintln(buff, "digraph network {")
+	fmt.Fprintln(buff, "\trankdir=LR;")
+
+	edges := func(to string, inputs []int) {
+		from := []int{}
+		for _, src := range inputs {
+			for _, b := range srcs[src] {
+				from = union(from, b)
+			}
+		}
+
+		for _, src := range from {
+			if f.match(n.Nodes[src]) {
+				fmt.Fprintf(buff, "\tn%v -> %v;\n", src, to)
+			}
+		}
+	}
+
+	for i, node := range n.Nodes {
+		if !visible(node.Op) || !f.match(node) {
+			continue
+		}
+
+		fmt.Fprintf(buff, "\tn%v [label=\"%v\", shape=%v];\n", i, label(node), shapes[node.Op])
+		edges(fmt.Sprintf("n%v", i), node.Inputs)
+	}
+
+	fmt.Fprintln(buff, "\toutput [label=\"Output\", shape=house];")
+	edges("output", []int{n.Output})
+
+	fmt.Fprintln(buff, "}")
+
+	_, err := w.Write(buff.Bytes())
+	return err
+}
@@ -0,0 +1,280 @@
+// Package network implements an intermediate representation for white-box constructions: a DAG of lookup tables, XOR
+// gates, AND gates, and affine transformations over GF(2), along with one evaluator, one serializer, and analysis passes
+// over the DAG. The chow, xiao, toy, and full constructions can each be converted into a network with their Network
+// method, so code generators and analyses only have to understand this package.
+package network
+
+import (
+	"github.com/OpenWhiteBox/primitives/matrix"
+)
+
+// Op is the operation computed by a node in a network.
+type Op byte
+
+const (
+	Input  Op = iota // The 16-byte input block.
+	Slice            // Width bytes of the input, starting at Offset.
+	Concat           // The concatenation of the inputs.
+	Nibble           // The high (Offset = 0) or low (Offset = 1) nibble of the input's first byte.
+	Join             // A byte whose high nibble is the first input and low nibble is the second.
+	Lookup           // The entry of Tables[Index] indexed by the input.
+	XOR              // The XOR of the inputs.
+	AND              // Width bytes; bit i is the AND of bits 2i and 2i+1 of the input.
+	Affine           // The input, transformed by Affines[Index].
+)
+
+// String returns the name of the operation.
+func (op Op) String() string {
+	return [...]string{"Input", "Slice", "Concat", "Nibble", "Join", "Lookup", "XOR", "AND", "Affine"}[op]
+}
+
+// Node is one operation in a network. Its inputs are the outputs of earlier nodes, identified by their index in the
+// network. Name, Round, Position, and Gate say which part of the construction a node came from. Round is numbered like
+// the rounds reported to a common.Tracer: -1 is the Prologue, i is Round(i), and the round after the last is the
+// Epilogue. Position is a byte-wise position in the state matrix and Gate is the index of a gate in a chain of XOR
+// tables; each is -1 when it doesn't apply.
+type Node struct {
+	Op     Op
+	Inputs []int
+
+	Offset, Width int // Used by Slice, Nibble, and AND.
+	Index         int // Used by Lookup and Affine.
+
+	Name                  string
+	Round, Position, Gate int
+}
+
+// Table is a lookup table from InWidth bytes to OutWidth bytes. The output for input i is
+// Data[OutWidth*i : OutWidth*(i+1)], where multi-byte inputs are read big-endian.
+type Table struct {
+	InWidth, OutWidth int
+	Data              []byte
+}
+
+// Get returns the output of the table on the given input.
+func (t *Table) Get(in []byte) []byte {
+	i := 0
+	for _, b := range in[:t.InWidth] {
+		i = i<<8 | int(b)
+	}
+
+	return t.Data[t.OutWidth*i : t.OutWidth*(i+1)]
+}
+
+// AffineLayer is an affine transformation over GF(2). It need not be square or invertible.
+type AffineLayer struct {
+	Linear   matrix.Matrix
+	Constant matrix.Row
+}
+
+// Network is a table network that computes a function on 16-byte blocks. Nodes are stored in topological order, so every
+// node's inputs come before it. The output of the node at index Output is the output of the network.
+type Network struct {
+	Nodes   []Node
+	Tables  []Table
+	Affines []AffineLayer
+
+	Output int
+
+	name            string
+	round, position int
+}
+
+// New returns a network with only the Input node.
+func New() *Network {
+	n := &Network{}
+	n.At("Input", -1, -1)
+	n.add(Node{Op: Input})
+
+	return n
+}
+
+// At sets the name, round, and position that are attached to nodes added after it.
+func (n *Network) At(name string, round, position int) {
+	n.name, n.round, n.position = name, round, position
+}
+
+func (n *Network) add(node Node) int {
+	node.Name, node.Round, node.Position, node.Gate = n.name, n.round, n.position, -1
+	n.Nodes = append(n.Nodes, node)
+
+	return len(n.Nodes) - 1
+}
+
+// Input returns the Input node.
+func (n *Network) Input() int { return 0 }
+
+// Slice adds a node that selects width bytes of src, starting at offset.
+func (n *Network) Slice(src, offset, width int) int {
+	return n.add(Node{Op: Slice, Inputs: []int{src}, Offset: offset, Width: width})
+}
+
+// Bytes slices src into its first width bytes, one node per byte.
+func (n *Network) Bytes(src, width int) []int {
+	out := make([]int, width)
+	for i := range out {
+		out[i] = n.Slice(src, i, 1)
+	}
+
+	return out
+}
+
+// Concat adds a node that concatenates srcs.
+func (n *Network) Concat(srcs ...int) int {
+	return n.add(Node{Op: Concat, Inputs: srcs})
+}
+
+// High adds a node that selects the high nibble of src.
+func (n *Network) High(src int) int {
+	return n.add(Node{Op: Nibble, Inputs: []int{src}, Offset: 0})
+}
+
+// Low adds a node that selects the low nibble of src.
+func (n *Network) Low(src int) int {
+	return n.add(Node{Op: Nibble, Inputs: []int{src}, Offset: 1})
+}
+
+// Join adds a node that concatenates the nibbles high and low into a byte.
+func (n *Network) Join(high, low int) int {
+	return n.add(Node{Op: Join, Inputs: []int{high, low}})
+}
+
+// AddTable stores t in the network and returns its index, for use with Lookup.
+func (n *Network) AddTable(t Table) int {
+	n.Tables = append(n.Tables, t)
+	return len(n.Tables) - 1
+}
+
+// Lookup adds a node that looks up the value of src in the table with the given index.
+func (n *Network) Lookup(table, src int) int {
+	return n.add(Node{Op: Lookup, Inputs: []int{src}, Index: table})
+}
+
+// XOR adds a node that XORs srcs together.
+func (n *Network) XOR(srcs ...int) int {
+	return n.add(Node{Op: XOR, Inputs: srcs})
+}
+
+// AND adds a node that ANDs neighboring bits of src together, producing width bytes.
+func (n *Network) AND(src, width int) int {
+	return n.add(Node{Op: AND, Inputs: []int{src}, Width: width})
+}
+
+// AddAffine stores a in the network and returns its index, for use with Transform.
+func (n *Network) AddAffine(a AffineLayer) int {
+	n.Affines = append(n.Affines, a)
+	return len(n.Affines) - 1
+}
+
+// Transform adds a node that applies the affine layer with the given index to src.
+func (n *Network) Transform(affine, src int) int {
+	return n.add(Node{Op: Affine, Inputs: []int{src}, Index: affine})
+}
+
+// SquashNibbles XORs srcs together, each width bytes long, with a chain of nibble-wise XOR tables. The running total is
+// the high nibble of each table's input and the next src is the low nibble. gate(nibble, i) is the index of the table
+// that adds src i+1 into the given nibble of the total. Each lookup is tagged with gate number i and the byte-wise
+// position it computes, counted from the position given to At.
+func (n *Network) SquashNibbles(srcs []int, width int, gate func(nibble, i int) int) int {
+	acc := make([]int, 2*width)
+	for pos := 0; pos < width; pos++ {
+		b := n.Slice(srcs[0], pos, 1)
+		acc[2*pos+0], acc[2*pos+1] = n.High(b), n.Low(b)
+	}
+
+	for i, src := range srcs[1:] {
+		for pos := 0; pos < width; pos++ {
+			b := n.Slice(src, pos, 1)
+
+			acc[2*pos+0] = n.Lookup(gate(2*pos+0, i), n.Join(acc[2*pos+0], n.High(b)))
+			acc[2*pos+1] = n.Lookup(gate(2*pos+1, i), n.Join(acc[2*pos+1], n.Low(b)))
+
+			for _, id := range acc[2*pos : 2*pos+2] {
+				n.Nodes[id].Position, n.Nodes[id].Gate = n.position+pos, i
+			}
+		}
+	}
+
+	out := make([]int, width)
+	for pos := range out {
+		out[pos] = n.Join(acc[2*pos+0], acc[2*pos+1])
+	}
+
+	return n.Concat(out...)
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (n *Network) BlockSize() int { return 16 }
+
+// Encrypt evaluates the network on the first block in src and writes the result to dst. Dst and src may point at the
+// same memory.
+func (n *Network) Encrypt(dst, src []byte) {
+	copy(dst, n.Eval(src[:16]))
+}
+
+// Decrypt is not implemented.
+func (n *Network) Decrypt(_, _ []byte) {}
+
+// Trace evaluates the network on in and returns the output of every node.
+func (n *Network) Trace(in []byte) [][]byte {
+	vals := make([][]byte, len(n.Nodes))
+
+	for i, node := range n.Nodes {
+		vals[i] = n.eval(node, vals, in)
+	}
+
+	return vals
+}
+
+// Eval evaluates the network on in and returns its output.
+func (n *Network) Eval(in []byte) []byte {
+	return append([]byte{}, n.Trace(in)[n.Output]...)
+}
+
+func (n *Network) eval(node Node, vals [][]byte, in []byte) []byte {
+	arg := func(i int) []byte { return vals[node.Inputs[i]] }
+
+	switch node.Op {
+	case Input:
+		return append([]byte{}, in[:16]...)
+	case Slice:
+		return arg(0)[node.Offset : node.Offset+node.Width]
+	case Concat:
+		out := []byte{}
+		for i := range node.Inputs {
+			out = append(out, arg(i)...)
+		}
+		return out
+	case Nibble:
+		if node.Offset == 0 {
+			return []byte{arg(0)[0] >> 4}
+		}
+		return []byte{arg(0)[0] & 0x0f}
+	case Join:
+		return []byte{arg(0)[0]<<4 | arg(1)[0]&0x0f}
+	case Lookup:
+		return n.Tables[node.Index].Get(arg(0))
+	case XOR:
+		out := append([]byte{}, arg(0)...)
+		for i := 1; i < len(node.Inputs); i++ {
+			for j, b := range arg(i) {
+				out[j] ^= b
+			}
+		}
+		return out
+	case AND:
+		src, out := arg(0), make([]byte, node.Width)
+		for i := 0; i < 8*node.Width; i++ {
+			b1 := src[(2*i+0)/8] >> uint((2*i+0)%8)
+			b2 := src[(2*i+1)/8] >> uint((2*i+1)%8)
+
+			out[i/8] |= (b1 & b2 & 1) << uint(i%8)
+		}
+		return out
+	case Affine:
+		a := n.Affines[node.Index]
+		return []byte(a.Linear.Mul(matrix.Row(arg(0))).Add(a.Constant))
+	default:
+		panic("Unknown operation in network!")
+	}
+}
@@ -0,0 +1,164 @@
+package network
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+)
+
+var input = []byte{99, 83, 224, 140, 9, 96, 225, 4, 205, 112, 183, 81, 186, 202, 208, 231}
+
+// example builds a small network that uses every operation.
+func example() *Network {
+	n := New()
+
+	double := Table{1, 1, make([]byte, 256)}
+	for i := 0; i < 256; i++ {
+		double.Data[i] = byte(2 * i)
+	}
+	t := n.AddTable(double)
+
+	identity := matrix.GenerateIdentity(128)
+	constant := matrix.NewRow(128)
+	constant[0] = 0xff
+	a := n.AddAffine(AffineLayer{identity, constant})
+
+	in := n.Input()
+
+	n.At("Example", 0, -1)
+	state := n.Bytes(in, 16)
+	looked := make([]int, 16)
+	for pos := range state {
+		looked[pos] = n.Lookup(t, n.Join(n.Low(state[pos]), n.High(state[pos])))
+	}
+
+	mixed := n.XOR(n.Concat(looked...), n.Transform(a, in))
+	n.Output = n.Concat(n.AND(mixed, 8), n.Slice(mixed, 8, 8))
+
+	return n
+}
+
+// reference computes the same function as example, by hand.
+func reference(in []byte) []byte {
+	mixed := make([]byte, 16)
+	for pos := range mixed {
+		mixed[pos] = 2*(in[pos]<<4|in[pos]>>4) ^ in[pos]
+	}
+	mixed[0] ^= 0xff
+
+	out := make([]byte, 16)
+	for i := 0; i < 64; i++ {
+		b1 := mixed[(2*i+0)/8] >> uint((2*i+0)%8)
+		b2 := mixed[(2*i+1)/8] >> uint((2*i+1)%8)
+
+		out[i/8] |= (b1 & b2 & 1) << uint(i%8)
+	}
+	copy(out[8:], mixed[8:])
+
+	return out
+}
+
+func TestEval(t *testing.T) {
+	real, cand := reference(input), example().Eval(input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with result! %x != %x", real, cand)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	n1 := example()
+
+	n2, err := Parse(n1.Serialize())
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	cand1, cand2 := n1.Eval(input), n2.Eval(input)
+
+	if !bytes.Equal(cand1, cand2) {
+		t.Fatalf("Real disagrees with parsed! %x != %x", cand1, cand2)
+	}
+
+	if _, err := Parse(n1.Serialize()[:100]); err == nil {
+		t.Fatalf("Parse accepted a truncated network!")
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	serialized := example().Serialize()
+
+	for i := 0; i < len(serialized); i++ {
+		if _, err := Parse(serialized[:i]); err == nil {
+			t.Fatalf("Parse accepted a network truncated to %v bytes!", i)
+		}
+	}
+
+	// Corrupting a byte may leave a valid network, but never one that panics.
+	for i := len(magic); i < len(serialized); i++ {
+		for _, b := range []byte{0x00, 0x01, 0x7f, 0x80, 0xff} {
+			corrupted := append([]byte{}, serialized...)
+			corrupted[i] = b
+
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("Network with byte %v set to %x panicked: %v", i, b, r)
+					}
+				}()
+
+				if n, err := Parse(corrupted); err == nil {
+					n.Eval(input)
+				}
+			}()
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	n := example()
+	stats := n.Stats()
+
+	if stats.Tables != 1 || stats.Lookups != 16 || stats.XORs != 1 || stats.ANDs != 1 || stats.Transforms != 1 {
+		t.Fatalf("Stats counted the wrong operations! %+v", stats)
+	}
+
+	if stats.Memory != 256+128*16+16 {
+		t.Fatalf("Stats counted the wrong amount of memory! %v", stats.Memory)
+	}
+
+	if widths := n.Widths(); widths[n.Output] != 16 {
+		t.Fatalf("Widths computed the wrong output width! %v", widths[n.Output])
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	n := example()
+
+	all := &bytes.Buffer{}
+	if err := n.WriteDOT(all, All); err != nil {
+		t.Fatalf("WriteDOT returned error: %v", err)
+	}
+
+	if !strings.HasPrefix(all.String(), "digraph") || !strings.Contains(all.String(), "n0 -> ") {
+		t.Fatalf("WriteDOT didn't draw the input's edges!\n%v", all)
+	} else if strings.Count(all.String(), "shape=box") != 16 {
+		t.Fatalf("WriteDOT didn't draw every lookup!\n%v", all)
+	}
+
+	round := &bytes.Buffer{}
+	n.WriteDOT(round, Filter{Round: 0, Column: -1})
+
+	if strings.Contains(round.String(), "n0 ") {
+		t.Fatalf("WriteDOT drew a node outside of the filter!\n%v", round)
+	}
+
+	column := &bytes.Buffer{}
+	n.WriteDOT(column, Filter{Round: AllRounds, Column: 0})
+
+	if strings.Count(column.String(), "shape=box") != 16 {
+		t.Fatalf("WriteDOT dropped nodes that aren't in any column!\n%v", column)
+	}
+}
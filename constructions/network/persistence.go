@@ -0,0 +1,261 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+)
+
+var magic = []byte("OWBN")
+
+// writer appends big-endian integers and byte strings to a buffer.
+type writer struct {
+	out []byte
+}
+
+func (w *writer) int(i int) {
+	var buff [4]byte
+	binary.BigEndian.PutUint32(buff[:], uint32(int32(i)))
+	w.out = append(w.out, buff[:]...)
+}
+
+func (w *writer) bytes(b []byte) {
+	w.int(len(b))
+	w.out = append(w.out, b...)
+}
+
+// reader reads what a writer wrote. Once a read fails, every later read returns zero values and err is set.
+type reader struct {
+	in  []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || n < 0 || len(r.in) < n {
+		r.err = errors.New("Parsing the network failed!")
+		return nil
+	}
+
+	out := r.in[:n]
+	r.in = r.in[n:]
+
+	return out
+}
+
+func (r *reader) int() int {
+	buff := r.next(4)
+	if buff == nil {
+		return 0
+	}
+
+	return int(int32(binary.BigEndian.Uint32(buff)))
+}
+
+// count reads the length of a list. It fails if the length is negative or longer than what's left to read, so that a
+// malformed length can't make the caller allocate more than the input could describe.
+func (r *reader) count() int {
+	n := r.int()
+	if n < 0 || n > len(r.in) {
+		r.err = errors.New("Parsing the network failed!")
+		return 0
+	}
+
+	return n
+}
+
+func (r *reader) bytes() []byte {
+	return append([]byte{}, r.next(r.int())...)
+}
+
+// Serialize serializes a network into a byte slice.
+func (n *Network) Serialize() []byte {
+	w := &writer{out: append([]byte{}, magic...)}
+
+	w.int(n.Output)
+
+	w.int(len(n.Tables))
+	for _, t := range n.Tables {
+		w.int(t.InWidth)
+		w.int(t.OutWidth)
+		w.bytes(t.Data)
+	}
+
+	w.int(len(n.Affines))
+	for _, a := range n.Affines {
+		w.int(len(a.Linear))
+		for _, row := range a.Linear {
+			w.bytes(row)
+		}
+		w.bytes(a.Constant)
+	}
+
+	w.int(len(n.Nodes))
+	for _, node := range n.Nodes {
+		w.int(int(node.Op))
+		w.int(len(node.Inputs))
+		for _, src := range node.Inputs {
+			w.int(src)
+		}
+
+		w.int(node.Offset)
+		w.int(node.Width)
+		w.int(node.Index)
+
+		w.bytes([]byte(node.Name))
+		w.int(node.Round)
+		w.int(node.Position)
+		w.int(node.Gate)
+	}
+
+	return w.out
+}
+
+// Parse parses a byte array into a network. It returns an error if the byte array is malformed or describes a network
+// that can't be evaluated, like one that isn't in topological order or that reads past the end of a node's input.
+func Parse(in []byte) (n *Network, err error) {
+	if len(in) < len(magic) || string(in[:len(magic)]) != string(magic) {
+		return nil, errors.New("Parsing the network failed!")
+	}
+
+	r := &reader{in: in[len(magic):]}
+	n = &Network{}
+
+	n.Output = r.int()
+
+	n.Tables = make([]Table, r.count())
+	for i := range n.Tables {
+		n.Tables[i] = Table{InWidth: r.int(), OutWidth: r.int(), Data: r.bytes()}
+	}
+
+	n.Affines = make([]AffineLayer, r.count())
+	for i := range n.Affines {
+		n.Affines[i].Linear = make(matrix.Matrix, r.count())
+		for j := range n.Affines[i].Linear {
+			n.Affines[i].Linear[j] = matrix.Row(r.bytes())
+		}
+		n.Affines[i].Constant = matrix.Row(r.bytes())
+	}
+
+	n.Nodes = make([]Node, r.count())
+	for i := range n.Nodes {
+		op := r.int()
+		if op < int(Input) || op > int(Affine) {
+			return nil, errors.New("Parsing the network failed!")
+		}
+		node := Node{Op: Op(op)}
+
+		node.Inputs = make([]int, r.count())
+		for j := range node.Inputs {
+			node.Inputs[j] = r.int()
+		}
+
+		node.Offset, node.Width, node.Index = r.int(), r.int(), r.int()
+		node.Name, node.Round, node.Position, node.Gate = string(r.bytes()), r.int(), r.int(), r.int()
+
+		n.Nodes[i] = node
+	}
+
+	if r.err != nil || len(r.in) != 0 || !n.valid() {
+		return nil, errors.New("Parsing the network failed!")
+	}
+
+	return n, nil
+}
+
+// valid checks that every node only depends on earlier nodes, has as many inputs as its operation takes, and only
+// reads bytes its inputs have; and that every table and affine layer a node uses exists and has the shape its
+// dimensions say. A valid network can be evaluated without panicking.
+func (n *Network) valid() bool {
+	if n.Output < 0 || n.Output >= len(n.Nodes) {
+		return false
+	}
+
+	for _, t := range n.Tables {
+		if t.InWidth < 1 || t.InWidth > 3 || t.OutWidth < 0 || t.OutWidth > len(t.Data) {
+			return false
+		} else if len(t.Data) != t.OutWidth<<uint(8*t.InWidth) {
+			return false
+		}
+	}
+
+	for _, a := range n.Affines {
+		if len(a.Linear) == 0 || len(a.Linear)%8 != 0 || len(a.Constant) != len(a.Linear)/8 {
+			return false
+		}
+
+		for _, row := range a.Linear {
+			if len(row) != len(a.Linear[0]) {
+				return false
+			}
+		}
+	}
+
+	widths := make([]int, len(n.Nodes))
+	for i, node := range n.Nodes {
+		for _, src := range node.Inputs {
+			if src < 0 || src >= i {
+				return false
+			}
+		}
+
+		// in returns the width of the node's input j.
+		in := func(j int) int { return widths[node.Inputs[j]] }
+
+		switch arity := len(node.Inputs); node.Op {
+		case Input:
+			if arity != 0 {
+				return false
+			}
+			widths[i] = 16
+		case Slice:
+			if arity != 1 || node.Offset < 0 || node.Width < 0 || node.Offset+node.Width > in(0) {
+				return false
+			}
+			widths[i] = node.Width
+		case Concat:
+			for j := range node.Inputs {
+				widths[i] += in(j)
+			}
+		case Nibble:
+			if arity != 1 || (node.Offset != 0 && node.Offset != 1) || in(0) < 1 {
+				return false
+			}
+			widths[i] = 1
+		case Join:
+			if arity != 2 || in(0) < 1 || in(1) < 1 {
+				return false
+			}
+			widths[i] = 1
+		case Lookup:
+			if arity != 1 || node.Index < 0 || node.Index >= len(n.Tables) || in(0) < n.Tables[node.Index].InWidth {
+				return false
+			}
+			widths[i] = n.Tables[node.Index].OutWidth
+		case XOR:
+			if arity < 1 {
+				return false
+			}
+			for j := range node.Inputs {
+				if in(j) > in(0) {
+					return false
+				}
+			}
+			widths[i] = in(0)
+		case AND:
+			if arity != 1 || node.Width < 0 || 2*node.Width > in(0) {
+				return false
+			}
+			widths[i] = node.Width
+		case Affine:
+			if arity != 1 || node.Index < 0 || node.Index >= len(n.Affines) || len(n.Affines[node.Index].Linear[0]) != in(0) {
+				return false
+			}
+			widths[i] = len(n.Affines[node.Index].Linear) / 8
+		default:
+			return false
+		}
+	}
+
+	return true
+}
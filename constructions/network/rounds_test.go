@@ -0,0 +1,90 @@
+package network_test
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/full"
+	"github.com/OpenWhiteBox/AES/constructions/network"
+	"github.com/OpenWhiteBox/AES/constructions/toy"
+	"github.com/OpenWhiteBox/AES/constructions/xiao"
+)
+
+var (
+	key  = []byte{0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6, 0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c}
+	seed = []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+)
+
+// rounds is a common.Tracer. It remembers which rounds it saw lookups in and which rounds it saw the state after.
+type rounds struct {
+	lookups map[int]bool
+	states  []int
+}
+
+func (r *rounds) OnLookup(round, _ int, _ string, _, _ []byte) { r.lookups[round] = true }
+
+func (r *rounds) OnState(round int, _ []byte) { r.states = append(r.states, round) }
+
+// TestRounds checks that every construction tags the nodes of its network with the same rounds its tracer reports.
+func TestRounds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the cross-construction network test in short mode!")
+	}
+
+	opts := common.IndependentMasks{common.RandomMask, common.RandomMask}
+
+	chowConstr, _, _ := chow.GenerateEncryptionKeys(key, seed, opts)
+	xiaoConstr, _, _ := xiao.GenerateEncryptionKeys(key, seed, opts)
+	toyConstr, _, _ := toy.GenerateKeys(key, seed)
+	fullConstr, _, _ := full.GenerateKeys(key, seed)
+
+	cases := []struct {
+		name   string
+		traced func(common.Tracer) cipher.Block
+		n      *network.Network
+	}{
+		{"chow", chowConstr.Traced, chowConstr.EncryptionNetwork()},
+		{"xiao", xiaoConstr.Traced, xiaoConstr.Network()},
+		{"toy", toyConstr.Traced, toyConstr.Network()},
+		{"full", fullConstr.Traced, fullConstr.Network()},
+	}
+
+	for _, c := range cases {
+		r := &rounds{lookups: make(map[int]bool)}
+		c.traced(r).Encrypt(make([]byte, 16), make([]byte, 16))
+
+		first, last := r.states[0], r.states[len(r.states)-1]
+		lookups := make(map[int]bool)
+
+		for i, node := range c.n.Nodes {
+			if node.Round < first || node.Round > last {
+				t.Fatalf("%v: node %v is tagged with round %v, outside of [%v, %v]!", c.name, i, node.Round, first, last)
+			}
+
+			if node.Op == network.Lookup || node.Op == network.AND {
+				lookups[node.Round] = true
+			}
+		}
+
+		if first != -1 || c.n.Nodes[c.n.Input()].Round != first {
+			t.Fatalf("%v: network doesn't start in the Prologue! %v, %v", c.name, first, c.n.Nodes[c.n.Input()].Round)
+		} else if !reflect.DeepEqual(lookups, r.lookups) {
+			t.Fatalf("%v: network and tracer disagree on rounds with lookups! %v != %v", c.name, sorted(lookups), sorted(r.lookups))
+		}
+	}
+}
+
+func sorted(set map[int]bool) string {
+	out := ""
+	for round := -1; round <= 10; round++ {
+		if set[round] {
+			out += fmt.Sprintf("%v ", round)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"github.com/OpenWhiteBox/primitives/table"
+)
+
+// FromNibble converts a nibble table into a Table. The output is in the low nibble of each entry.
+func FromNibble(t table.Nibble) Table {
+	out := Table{1, 1, make([]byte, 256)}
+	for i := 0; i < 256; i++ {
+		out.Data[i] = t.Get(byte(i)) & 0x0f
+	}
+
+	return out
+}
+
+// FromByte converts a byte table into a Table.
+func FromByte(t table.Byte) Table {
+	out := Table{1, 1, make([]byte, 256)}
+	for i := 0; i < 256; i++ {
+		out.Data[i] = t.Get(byte(i))
+	}
+
+	return out
+}
+
+// FromWord converts a byte-to-word table into a Table.
+func FromWord(t table.Word) Table {
+	out := Table{1, 4, make([]byte, 256*4)}
+	for i := 0; i < 256; i++ {
+		res := t.Get(byte(i))
+		copy(out.Data[4*i:], res[:])
+	}
+
+	return out
+}
+
+// FromBlock converts a byte-to-block table into a Table.
+func FromBlock(t table.Block) Table {
+	out := Table{1, 16, make([]byte, 256*16)}
+	for i := 0; i < 256; i++ {
+		res := t.Get(byte(i))
+		copy(out.Data[16*i:], res[:])
+	}
+
+	return out
+}
+
+// FromDoubleToByte converts a double-to-byte table into a Table.
+func FromDoubleToByte(t table.DoubleToByte) Table {
+	out := Table{2, 1, make([]byte, 65536)}
+	for i := 0; i < 65536; i++ {
+		out.Data[i] = t.Get([2]byte{byte(i >> 8), byte(i)})
+	}
+
+	return out
+}
+
+// FromDoubleToWord converts a double-to-word table into a Table.
+func FromDoubleToWord(t table.DoubleToWord) Table {
+	out := Table{2, 4, make([]byte, 65536*4)}
+	for i := 0; i < 65536; i++ {
+		res := t.Get([2]byte{byte(i >> 8), byte(i)})
+		copy(out.Data[4*i:], res[:])
+	}
+
+	return out
+}
@@ -1,5 +1,6 @@
 // Package saes implements a reference copy of AES-128.  It's useful for stealing AES' internals or seeing the ways you
-// can garble them without affecting its output.
+// can garble them without affecting its output. Variant also computes reduced-round AES, and exposes the intermediate
+// state of an encryption.
 package saes
 
 import (
@@ -20,39 +21,12 @@ func (constr Construction) BlockSize() int { return 16 }
 
 // Encrypt encrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Encrypt(dst, src []byte) {
-	roundKeys := constr.StretchedKey()
-	copy(dst, src[:constr.BlockSize()])
-
-	constr.AddRoundKey(roundKeys[0], dst)
-	for i := 1; i <= 9; i++ {
-		constr.SubBytes(dst)
-		constr.ShiftRows(dst)
-		constr.MixColumns(dst)
-		constr.AddRoundKey(roundKeys[i], dst)
-	}
-
-	constr.SubBytes(dst)
-	constr.ShiftRows(dst)
-	constr.AddRoundKey(roundKeys[10], dst)
+	Variant{Key: constr.Key}.Encrypt(dst, src)
 }
 
 // Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Decrypt(dst, src []byte) {
-	roundKeys := constr.StretchedKey()
-	copy(dst, src[:constr.BlockSize()])
-
-	constr.AddRoundKey(roundKeys[10], dst)
-	constr.UnShiftRows(dst)
-	constr.UnSubBytes(dst)
-
-	for i := 9; i >= 1; i-- {
-		constr.AddRoundKey(roundKeys[i], dst)
-		constr.UnMixColumns(dst)
-		constr.UnShiftRows(dst)
-		constr.UnSubBytes(dst)
-	}
-
-	constr.AddRoundKey(roundKeys[0], dst)
+	Variant{Key: constr.Key}.Decrypt(dst, src)
 }
 
 func rotw(w uint32) uint32 { return w<<8 | w>>24 }
@@ -165,6 +139,17 @@ func (constr *Construction) UnSubByte(e byte) byte {
 	return byte(number.ByteFieldElem(invVal).Invert())
 }
 
+// SBox and InvSBox are SubByte and UnSubByte as tables, for code that evaluates them often.
+var SBox, InvSBox = func() (sbox, invSBox [256]byte) {
+	constr := Construction{}
+	for x := 0; x < 256; x++ {
+		y := constr.SubByte(byte(x))
+		sbox[x], invSBox[y] = y, byte(x)
+	}
+
+	return
+}()
+
 // ShiftRows permutes the first sixteen bytes of block with a fixed permutation.
 func (constr *Construction) ShiftRows(block []byte) {
 	copy(block, []byte{
@@ -62,6 +62,16 @@ func TestUnSubByte(t *testing.T) {
 	}
 }
 
+func TestSBox(t *testing.T) {
+	constr := Construction{key}
+
+	for i := 0; i < 256; i++ {
+		if SBox[i] != constr.SubByte(byte(i)) || InvSBox[i] != constr.UnSubByte(byte(i)) {
+			t.Fatalf("S-box tables disagree with SubByte and UnSubByte at %v!", i)
+		}
+	}
+}
+
 func TestKeyStretching(t *testing.T) {
 	real := [11][]byte{
 		[]byte{72, 101, 108, 108, 111, 32, 87, 111, 114, 108, 100, 33, 33, 33, 33, 33},
@@ -211,6 +221,59 @@ func TestDecrypt(t *testing.T) {
 	}
 }
 
+func TestVariant(t *testing.T) {
+	constr := Construction{key}
+	in := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+	// With only a key, a variant is standard AES. Its observer sees the output last.
+	real, cand, last := make([]byte, 16), make([]byte, 16), make([]byte, 16)
+	steps := 0
+
+	constr.Encrypt(real, in)
+	Variant{Key: key, Observe: func(round int, step Step, state []byte) {
+		steps++
+		copy(last, state)
+	}}.Encrypt(cand, in)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with result! %x != %x", real, cand)
+	} else if !bytes.Equal(real, last) {
+		t.Fatalf("Observer didn't see the output last! %x != %x", real, last)
+	} else if steps != 1+4*9+3 {
+		t.Fatalf("Observer saw the wrong number of steps! %v", steps)
+	}
+
+	// One round with MixColumns, computed by hand.
+	roundKeys := constr.StretchedKey()
+
+	copy(real, in)
+	constr.AddRoundKey(roundKeys[0], real)
+	constr.SubBytes(real)
+	constr.ShiftRows(real)
+	constr.MixColumns(real)
+	constr.AddRoundKey(roundKeys[1], real)
+
+	v := Variant{Key: key, Rounds: 1, LastMixColumns: true}
+	v.Encrypt(cand, in)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with result! %x != %x", real, cand)
+	}
+
+	// Reduced-round variants still decrypt.
+	for rounds := 1; rounds <= 10; rounds++ {
+		for _, mix := range []bool{false, true} {
+			v := Variant{Key: key, Rounds: rounds, LastMixColumns: mix}
+			v.Encrypt(cand, in)
+			v.Decrypt(cand, cand)
+
+			if !bytes.Equal(in, cand) {
+				t.Fatalf("Decryption of %v rounds failed! %x != %x", rounds, in, cand)
+			}
+		}
+	}
+}
+
 func TestCBC(t *testing.T) {
 	// Vector stolen from crypto/aes/cbc_aes_test.go
 	key := []byte{0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6, 0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c}
@@ -0,0 +1,116 @@
+package saes
+
+// Step is a step of an AES round, after which a Variant reports the state.
+type Step int
+
+const (
+	AfterSubBytes Step = iota
+	AfterShiftRows
+	AfterMixColumns
+	AfterAddRoundKey
+)
+
+// String returns the name of the step.
+func (s Step) String() string {
+	switch s {
+	case AfterSubBytes:
+		return "SubBytes"
+	case AfterShiftRows:
+		return "ShiftRows"
+	case AfterMixColumns:
+		return "MixColumns"
+	case AfterAddRoundKey:
+		return "AddRoundKey"
+	default:
+		return "Unknown"
+	}
+}
+
+// Observer is called with the state after each step of each round of an encryption. Round 0 is the initial
+// AddRoundKey, and rounds 1 to Rounds are the full ones. The state is only valid during the call.
+type Observer func(round int, step Step, state []byte)
+
+// Variant is AES-128 with fewer rounds, or with hooks into its intermediate state. It gives attacks small instances to
+// work on and ground truth to check their guesses against. A Variant with only a Key set is standard AES.
+type Variant struct {
+	// A 16-byte AES key.
+	Key []byte
+
+	// Rounds is the number of rounds, from 1 to 10. Zero means 10.
+	Rounds int
+
+	// LastMixColumns keeps MixColumns in the last round. Standard AES drops it.
+	LastMixColumns bool
+
+	// Observe, if not nil, is called after every step of encryption.
+	Observe Observer
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (v Variant) BlockSize() int { return 16 }
+
+// rounds returns the number of rounds to compute.
+func (v Variant) rounds() int {
+	if v.Rounds == 0 {
+		return 10
+	} else if v.Rounds < 0 || v.Rounds > 10 {
+		panic("Rounds is out of range!")
+	}
+
+	return v.Rounds
+}
+
+// mixes returns true if the given round has MixColumns.
+func (v Variant) mixes(round int) bool {
+	return round < v.rounds() || v.LastMixColumns
+}
+
+func (v Variant) observe(round int, step Step, state []byte) {
+	if v.Observe != nil {
+		v.Observe(round, step, state)
+	}
+}
+
+// Encrypt encrypts the first block in src into dst. Dst and src may point at the same memory.
+func (v Variant) Encrypt(dst, src []byte) {
+	constr := Construction{v.Key}
+	roundKeys := constr.StretchedKey()
+	copy(dst, src[:v.BlockSize()])
+
+	constr.AddRoundKey(roundKeys[0], dst)
+	v.observe(0, AfterAddRoundKey, dst)
+
+	for i := 1; i <= v.rounds(); i++ {
+		constr.SubBytes(dst)
+		v.observe(i, AfterSubBytes, dst)
+
+		constr.ShiftRows(dst)
+		v.observe(i, AfterShiftRows, dst)
+
+		if v.mixes(i) {
+			constr.MixColumns(dst)
+			v.observe(i, AfterMixColumns, dst)
+		}
+
+		constr.AddRoundKey(roundKeys[i], dst)
+		v.observe(i, AfterAddRoundKey, dst)
+	}
+}
+
+// Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory. It doesn't call Observe.
+func (v Variant) Decrypt(dst, src []byte) {
+	constr := Construction{v.Key}
+	roundKeys := constr.StretchedKey()
+	copy(dst, src[:v.BlockSize()])
+
+	for i := v.rounds(); i >= 1; i-- {
+		constr.AddRoundKey(roundKeys[i], dst)
+		if v.mixes(i) {
+			constr.UnMixColumns(dst)
+		}
+		constr.UnShiftRows(dst)
+		constr.UnSubBytes(dst)
+	}
+
+	constr.AddRoundKey(roundKeys[0], dst)
+}
@@ -136,6 +136,14 @@ func GenerateKeys(key, seed []byte) (out Construction, inputMask, outputMask enc
 	out[10], _ = encoding.DecomposeBlockAffine(encoding.ComposedBlocks{out[10], outputMask})
 
 	// Sample a self-equivalences of the S-box layer and mix them into adjacent affine layers.
+	mixSelfEquivalences(&rs, &out)
+
+	return
+}
+
+// mixSelfEquivalences samples a self-equivalence of each S-box layer and mixes it into the adjacent affine layers. The
+// function computed by the construction is unchanged.
+func mixSelfEquivalences(rs *random.Source, out *Construction) {
 	label := make([]byte, 16)
 	copy(label, []byte("Self-Eq"))
 	r := rs.Stream(label)
@@ -145,6 +153,14 @@ func GenerateKeys(key, seed []byte) (out Construction, inputMask, outputMask enc
 		out[i-1], _ = encoding.DecomposeBlockAffine(encoding.ComposedBlocks{out[i-1], a})
 		out[i], _ = encoding.DecomposeBlockAffine(encoding.ComposedBlocks{bInv, out[i]})
 	}
+}
 
-	return
+// Rerandomize mixes fresh self-equivalences, generated by `seed`, into an existing construction. The returned
+// construction computes the same function with the same external masks, but shares no affine layers with the original.
+// The AES key isn't needed.
+func Rerandomize(constr Construction, seed []byte) Construction {
+	rs := random.NewSource("Toy Rerandomization", seed)
+	mixSelfEquivalences(&rs, &constr)
+
+	return constr
 }
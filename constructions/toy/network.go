@@ -0,0 +1,63 @@
+package toy
+
+import (
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/matrix"
+	"github.com/OpenWhiteBox/primitives/number"
+
+	"github.com/OpenWhiteBox/AES/constructions/network"
+)
+
+// Network converts the construction into a table network. The S-box layers become lookups in one shared table of field
+// inverses. Nodes are tagged with the rounds of Prologue and Round; the Epilogue is the identity, so it has none.
+func (constr *Construction) Network() *network.Network {
+	n := network.New()
+
+	inverse := network.Table{1, 1, make([]byte, 256)}
+	for i := 0; i < 256; i++ {
+		inverse.Data[i] = byte(number.ByteFieldElem(i).Invert())
+	}
+	inv := n.AddTable(inverse)
+
+	affine := func(round int) int {
+		return n.AddAffine(affineLayer(constr[round]))
+	}
+
+	n.At("Affine", -1, -1)
+	state := n.Transform(affine(0), n.Input())
+
+	for round := 0; round < 10; round++ {
+		sbox := make([]int, 16)
+		for pos := 0; pos < 16; pos++ {
+			n.At("SubBytes", round, pos)
+			sbox[pos] = n.Lookup(inv, n.Slice(state, pos, 1))
+		}
+
+		n.At("Affine", round, -1)
+		state = n.Transform(affine(round+1), n.Concat(sbox...))
+	}
+
+	n.Output = state
+
+	return n
+}
+
+// affineLayer recovers the matrix and constant of an affine block encoding by evaluating it on the basis vectors.
+func affineLayer(enc encoding.BlockAffine) network.AffineLayer {
+	constant := enc.Encode([16]byte{})
+	out := network.AffineLayer{Linear: matrix.GenerateEmpty(128, 128), Constant: matrix.Row(constant[:])}
+
+	for j := 0; j < 128; j++ {
+		in := [16]byte{}
+		in[j/8] = 1 << uint(j%8)
+
+		col := enc.Encode(in)
+		for i := 0; i < 128; i++ {
+			if (col[i/8]^constant[i/8])>>uint(i%8)&1 == 1 {
+				out.Linear[i].SetBit(j, true)
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,93 @@
+package toy
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/primitives/number"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// Prologue returns the part of the construction before the first round: an affine layer that removes the input mask and
+// adds the first round key.
+//
+// A construction is its Prologue, then Round(0) through Round(9), then its Epilogue.
+func (constr *Construction) Prologue() cipher.Block {
+	return layer{constr, 0, false, nil}
+}
+
+// Round returns round i of the construction, for 0 <= i < 10: the S-box layer and the affine layer after it. See
+// Prologue.
+func (constr *Construction) Round(i int) cipher.Block {
+	return layer{constr, i + 1, true, nil}
+}
+
+// Epilogue returns the part of the construction after the last round. The output mask is part of Round(9), so it's the
+// identity. See Prologue.
+func (constr *Construction) Epilogue() cipher.Block {
+	return common.Identity{10, nil}
+}
+
+// layer is one affine layer of a construction, optionally preceded by the S-box layer. If tracer isn't nil, it's told
+// about every S-box and the state after the layer. Layer i is round i-1.
+type layer struct {
+	constr *Construction
+	index  int
+	sboxes bool
+
+	tracer common.Tracer
+}
+
+// sbox inverts the byte at the given position of the state.
+func (l layer) sbox(state *[16]byte, pos int) {
+	in := state[pos]
+	state[pos] = byte(number.ByteFieldElem(in).Invert())
+
+	if l.tracer != nil {
+		l.tracer.OnLookup(l.index-1, pos, "SBox", []byte{in}, state[pos:pos+1])
+	}
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (l layer) BlockSize() int { return 16 }
+
+// Encrypt pushes the first block in src through the layer and writes the result to dst. Dst and src may point at the
+// same memory.
+func (l layer) Encrypt(dst, src []byte) {
+	state := [16]byte{}
+	copy(state[:], src[:])
+
+	if l.sboxes {
+		for pos := 0; pos < 16; pos++ {
+			l.sbox(&state, pos)
+		}
+	}
+
+	state = l.constr[l.index].Encode(state)
+
+	if l.tracer != nil {
+		l.tracer.OnState(l.index-1, state[:])
+	}
+
+	copy(dst[:], state[:])
+}
+
+// Decrypt inverts Encrypt.
+func (l layer) Decrypt(dst, src []byte) {
+	state := [16]byte{}
+	copy(state[:], src[:])
+
+	state = l.constr[l.index].Decode(state)
+
+	if l.sboxes {
+		for pos := 0; pos < 16; pos++ {
+			l.sbox(&state, pos)
+		}
+	}
+
+	if l.tracer != nil {
+		l.tracer.OnState(l.index-1, state[:])
+	}
+
+	copy(dst[:], state[:])
+}
@@ -5,7 +5,8 @@ package toy
 
 import (
 	"github.com/OpenWhiteBox/primitives/encoding"
-	"github.com/OpenWhiteBox/primitives/number"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
 )
 
 type Construction [11]encoding.BlockAffine
@@ -15,36 +16,33 @@ func (constr Construction) BlockSize() int { return 16 }
 
 // Encrypt encrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Encrypt(dst, src []byte) {
-	state := [16]byte{}
-	copy(state[:], src[:])
+	constr.encrypt(dst, src, nil)
+}
 
-	state = constr[0].Encode(state)
+// Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
+func (constr Construction) Decrypt(dst, src []byte) {
+	constr.decrypt(dst, src, nil)
+}
 
-	for round := 1; round < 11; round++ {
-		for pos := 0; pos < 16; pos++ {
-			state[pos] = byte(number.ByteFieldElem(state[pos]).Invert())
-		}
+// encrypt implements Encrypt. If t isn't nil, it's told about every S-box and the state after every round.
+func (constr *Construction) encrypt(dst, src []byte, t common.Tracer) {
+	copy(dst, src[:16])
+	layer{constr, 0, false, t}.Encrypt(dst, dst)
 
-		state = constr[round].Encode(state)
+	for round := 0; round < 10; round++ {
+		layer{constr, round + 1, true, t}.Encrypt(dst, dst)
 	}
 
-	copy(dst[:], state[:])
+	common.Identity{10, t}.Encrypt(dst, dst)
 }
 
-// Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
-func (constr Construction) Decrypt(dst, src []byte) {
-	state := [16]byte{}
-	copy(state[:], src[:])
-
-	state = constr[10].Decode(state)
+// decrypt implements Decrypt. See encrypt.
+func (constr *Construction) decrypt(dst, src []byte, t common.Tracer) {
+	common.Identity{10, t}.Decrypt(dst, src)
 
 	for round := 9; round >= 0; round-- {
-		for pos := 0; pos < 16; pos++ {
-			state[pos] = byte(number.ByteFieldElem(state[pos]).Invert())
-		}
-
-		state = constr[round].Decode(state)
+		layer{constr, round + 1, true, t}.Decrypt(dst, dst)
 	}
 
-	copy(dst[:], state[:])
+	layer{constr, 0, false, t}.Decrypt(dst, dst)
 }
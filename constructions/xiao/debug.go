@@ -0,0 +1,72 @@
+package xiao
+
+import (
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/matrix"
+	"github.com/OpenWhiteBox/primitives/random"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// DecodeStates pushes the plaintext block through an encryption construction and decodes the state after its Prologue
+// (stage 0), after each Round(r) for r < 9 (stage r+1), and after its Epilogue (stage 10) into common.States. The
+// internal and external encodings are regenerated from the seed and opts the construction was generated with; the masks
+// are the ones it returned.
+//
+// Only encryption constructions are supported. A decryption construction's encodings come from a different random
+// source, and its rounds are the inverse cipher's, which common.States doesn't describe.
+func DecodeStates(constr *Construction, seed []byte, opts common.KeyGenerationOpts, inputMask, outputMask matrix.Matrix, block []byte) (out common.States) {
+	rs := random.NewSource("Xiao Encryption", seed)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	input, output := common.GenerateExternalEncodings(seed, opts)
+
+	in := [16]byte{}
+	copy(in[:], inputInv.Mul(matrix.Row(block[:16])))
+	in = input.Encode(in)
+	state := in[:]
+
+	// The Prologue leaves the plaintext shifted and under the first round's input encodings.
+	constr.Prologue().Encrypt(state, state)
+
+	swapInv, _ := maskSwap(&rs, 16, 0).Invert()
+	copy(out[0][:], swapInv.Mul(matrix.Row(state)))
+	aes := saes.Construction{}
+	aes.UnShiftRows(out[0][:])
+
+	// An affine external encoding on the input is only cancelled by the first round key, so it's still on the state.
+	constant := input.Encode([16]byte{})
+	encoding.XOR(out[0][:], out[0][:], inputMask.Mul(matrix.Row(constant[:])))
+
+	// Every other round's output is under the inverse of its output mixing bijections, which the next barrier removes.
+	for round := 0; round < 10; round++ {
+		if round > 0 {
+			constr.Barrier(round).Encrypt(state, state)
+		}
+		constr.Round(round).Encrypt(state, state)
+
+		if round < 9 {
+			copy(out[round+1][:], maskSwap(&rs, 32, round).Mul(matrix.Row(state)))
+		}
+	}
+
+	constr.Epilogue().Encrypt(state, state)
+	copy(out[10][:], state)
+	out[10] = output.Decode(out[10])
+	copy(out[10][:], outputInv.Mul(matrix.Row(out[10][:])))
+
+	return
+}
+
+// Debug checks an encryption construction against AES with the given key, round by round, on the plaintext block. It
+// returns a *common.Divergence locating the first byte of the decoded state that's wrong, or nil if there is none. See
+// DecodeStates for why decryption constructions aren't supported.
+func Debug(constr *Construction, key, seed []byte, opts common.KeyGenerationOpts, inputMask, outputMask matrix.Matrix, block []byte) error {
+	return common.Compare(
+		common.ExpectedStates(key, block),
+		DecodeStates(constr, seed, opts, inputMask, outputMask, block),
+	)
+}
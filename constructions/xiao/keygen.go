@@ -63,7 +63,17 @@ func generateBarriers(rs *random.Source, out *Construction, inputMask, outputMas
 
 // GenerateEncryptionKeys creates a white-boxed version of the AES key `key` for encryption, with any non-determinism
 // generated by `seed`.
+//
+// Opts may ask for affine external encodings, but not nonlinear ones: the outermost layers of Xiao-Lai are linear
+// transformations, which can't hold them. GenerateEncryptionKeys panics if it does.
 func GenerateEncryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	return generateEncryptionKeys(key, seed, nil, opts)
+}
+
+// generateEncryptionKeys implements GenerateEncryptionKeys. If device isn't nil, the construction is bound to the
+// device mask it points to.
+func generateEncryptionKeys(key, seed []byte, device *[16]byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	checkOpts(opts)
 	rs := random.NewSource("Xiao Encryption", seed)
 
 	constr := saes.Construction{key}
@@ -74,20 +84,29 @@ func GenerateEncryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (ou
 		constr.ShiftRows(roundKeys[k])
 	}
 
+	common.GenerateMasks(&rs, common.MaskOpts(opts), &inputMask, &outputMask)
+	first, last := externalTweaks(seed, opts, &inputMask, &outputMask, constr.ShiftRows)
+
 	hidden := func(round, pos int) table.DoubleToWord {
 		if round == 9 {
 			return tBox{
 				[2]table.Byte{
-					common.TBox{constr, roundKeys[9][pos+0], roundKeys[10][pos+0]},
-					common.TBox{constr, roundKeys[9][pos+1], roundKeys[10][pos+1]},
+					common.TBox{constr, roundKeys[9][pos+0], roundKeys[10][pos+0] ^ last[pos+0]},
+					common.TBox{constr, roundKeys[9][pos+1], roundKeys[10][pos+1] ^ last[pos+1]},
 				},
 				sideFromPos(pos),
 			}
 		} else {
+			keyBytes := roundKeys[round][pos : pos+2]
+			if round == 0 {
+				tweak := common.DeviceTweak(device, &inputMask, constr.ShiftRows)
+				keyBytes = []byte{keyBytes[0] ^ tweak[pos+0] ^ first[pos+0], keyBytes[1] ^ tweak[pos+1] ^ first[pos+1]}
+			}
+
 			return tBoxMixCol{
 				[2]table.Byte{
-					common.TBox{constr, roundKeys[round][pos+0], 0x00},
-					common.TBox{constr, roundKeys[round][pos+1], 0x00},
+					common.TBox{constr, keyBytes[0], 0x00},
+					common.TBox{constr, keyBytes[1], 0x00},
 				},
 				mixColumns,
 				sideFromPos(pos),
@@ -95,7 +114,6 @@ func GenerateEncryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (ou
 		}
 	}
 
-	common.GenerateMasks(&rs, opts, &inputMask, &outputMask)
 	generateRoundMaterial(&rs, &out, hidden)
 	generateBarriers(&rs, &out, &inputMask, &outputMask, &shiftRows)
 
@@ -104,7 +122,17 @@ func GenerateEncryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (ou
 
 // GenerateDecryptionKeys creates a white-boxed version of the AES key `key` for decryption, with any non-determinism
 // generated by `seed`.
+//
+// Opts may ask for affine external encodings, but not nonlinear ones: the outermost layers of Xiao-Lai are linear
+// transformations, which can't hold them. GenerateDecryptionKeys panics if it does.
 func GenerateDecryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	return generateDecryptionKeys(key, seed, nil, opts)
+}
+
+// generateDecryptionKeys implements GenerateDecryptionKeys. If device isn't nil, the construction is bound to the
+// device mask it points to.
+func generateDecryptionKeys(key, seed []byte, device *[16]byte, opts common.KeyGenerationOpts) (out Construction, inputMask, outputMask matrix.Matrix) {
+	checkOpts(opts)
 	rs := random.NewSource("Xiao Decryption", seed)
 
 	constr := saes.Construction{key}
@@ -113,12 +141,17 @@ func GenerateDecryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (ou
 	// Apply UnShiftRows to round keys 10.
 	constr.UnShiftRows(roundKeys[10])
 
+	common.GenerateMasks(&rs, common.MaskOpts(opts), &inputMask, &outputMask)
+	first, last := externalTweaks(seed, opts, &inputMask, &outputMask, constr.UnShiftRows)
+
 	hidden := func(round, pos int) table.DoubleToWord {
 		if round == 0 {
+			tweak := common.DeviceTweak(device, &inputMask, constr.UnShiftRows)
+
 			return tBoxMixCol{
 				[2]table.Byte{
-					common.InvTBox{constr, roundKeys[10][pos+0], roundKeys[9][pos+0]},
-					common.InvTBox{constr, roundKeys[10][pos+1], roundKeys[9][pos+1]},
+					common.InvTBox{constr, roundKeys[10][pos+0] ^ tweak[pos+0] ^ first[pos+0], roundKeys[9][pos+0]},
+					common.InvTBox{constr, roundKeys[10][pos+1] ^ tweak[pos+1] ^ first[pos+1], roundKeys[9][pos+1]},
 				},
 				unMixColumns,
 				sideFromPos(pos),
@@ -135,15 +168,14 @@ func GenerateDecryptionKeys(key, seed []byte, opts common.KeyGenerationOpts) (ou
 		} else {
 			return tBox{
 				[2]table.Byte{
-					common.InvTBox{constr, 0x00, roundKeys[0][pos+0]},
-					common.InvTBox{constr, 0x00, roundKeys[0][pos+1]},
+					common.InvTBox{constr, 0x00, roundKeys[0][pos+0] ^ last[pos+0]},
+					common.InvTBox{constr, 0x00, roundKeys[0][pos+1] ^ last[pos+1]},
 				},
 				sideFromPos(pos),
 			}
 		}
 	}
 
-	common.GenerateMasks(&rs, opts, &inputMask, &outputMask)
 	generateRoundMaterial(&rs, &out, hidden)
 	generateBarriers(&rs, &out, &inputMask, &outputMask, &unShiftRows)
 
@@ -115,3 +115,27 @@ func maskSwap(rs *random.Source, size, round int) (out matrix.Matrix) {
 
 	return
 }
+
+// checkOpts panics if opts asks for external encodings that Xiao-Lai can't have. Its outermost layers are linear, so
+// the external encodings can only be affine.
+func checkOpts(opts common.KeyGenerationOpts) {
+	if ext, ok := opts.(common.ExternalEncodings); ok {
+		if ext.Input == common.NonlinearEncoding || ext.Output == common.NonlinearEncoding {
+			panic("Xiao-Lai can't have nonlinear external encodings!")
+		}
+	}
+}
+
+// externalTweaks returns what to XOR into the first and last round keys of a white-box so that it computes the affine
+// external encodings in opts on top of its masks.
+func externalTweaks(seed []byte, opts common.KeyGenerationOpts, inputMask, outputMask *matrix.Matrix, shift func([]byte)) (first, last []byte) {
+	input, output := common.GenerateExternalEncodings(seed, opts)
+	inputConst, outputConst := input.Encode([16]byte{}), output.Encode([16]byte{})
+
+	first = common.DeviceTweak(&inputConst, inputMask, shift)
+
+	outputInv, _ := outputMask.Invert()
+	last = outputInv.Mul(matrix.Row(outputConst[:]))
+
+	return
+}
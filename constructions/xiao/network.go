@@ -0,0 +1,47 @@
+package xiao
+
+import (
+	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/network"
+)
+
+// Network converts the construction into a table network.
+func (constr *Construction) Network() *network.Network {
+	n := network.New()
+
+	linear := func(m matrix.Matrix) int {
+		return n.AddAffine(network.AffineLayer{Linear: m, Constant: matrix.NewRow(len(m))})
+	}
+
+	state := n.Input()
+
+	for round := 0; round < 10; round++ {
+		// The first ShiftRows matrix is the Prologue; the others are part of the round they precede.
+		if round == 0 {
+			n.At("ShiftRows", -1, -1)
+		} else {
+			n.At("ShiftRows", round, -1)
+		}
+		state = n.Transform(linear(constr.ShiftRows[round]), state)
+
+		words := make([]int, 4)
+		for pos := 0; pos < 16; pos += 4 {
+			n.At("TBoxMixCol", round, pos)
+			left := n.Lookup(n.AddTable(network.FromDoubleToWord(constr.TBoxMixCol[round][pos/2])), n.Slice(state, pos, 2))
+
+			n.At("TBoxMixCol", round, pos+2)
+			right := n.Lookup(n.AddTable(network.FromDoubleToWord(constr.TBoxMixCol[round][pos/2+1])), n.Slice(state, pos+2, 2))
+
+			n.At("XOR", round, pos)
+			words[pos/4] = n.XOR(left, right)
+		}
+
+		state = n.Concat(words...)
+	}
+
+	n.At("FinalMask", 10, -1)
+	n.Output = n.Transform(linear(constr.FinalMask), state)
+
+	return n
+}
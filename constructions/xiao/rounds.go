@@ -0,0 +1,83 @@
+package xiao
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// Prologue returns the part of the construction before the first round: the first ShiftRows matrix, which also removes
+// the input mask. It is the same as Barrier(0).
+//
+// A construction is Barrier(0), Round(0), Barrier(1), Round(1), ..., Barrier(9), Round(9), then its Epilogue. This is the
+// same for encryption and decryption.
+func (constr *Construction) Prologue() cipher.Block {
+	return constr.Barrier(0)
+}
+
+// Barrier returns the ShiftRows matrix before round i, for 0 <= i < 10. It permutes the state and re-encodes it for the
+// round's tables. See Prologue.
+func (constr *Construction) Barrier(i int) cipher.Block {
+	return linearLayer{constr.ShiftRows[i]}
+}
+
+// Round returns round i of the construction, for 0 <= i < 10: the TBoxMixCol tables and the XORs that combine their
+// output. See Prologue.
+func (constr *Construction) Round(i int) cipher.Block {
+	return round{constr, i, nil}
+}
+
+// Epilogue returns the part of the construction after the last round: the FinalMask. See Prologue.
+func (constr *Construction) Epilogue() cipher.Block {
+	return linearLayer{constr.FinalMask}
+}
+
+// linearLayer is a linear transformation of the state.
+type linearLayer struct {
+	linear matrix.Matrix
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (ll linearLayer) BlockSize() int { return 16 }
+
+// Encrypt applies the transformation to the first block in src and writes the result to dst. Dst and src may point at
+// the same memory.
+func (ll linearLayer) Encrypt(dst, src []byte) {
+	copy(dst, ll.linear.Mul(matrix.Row(src[:16])))
+}
+
+// Decrypt is not implemented.
+func (ll linearLayer) Decrypt(_, _ []byte) {}
+
+// round is one round of a construction. If tracer isn't nil, it's told about every lookup.
+type round struct {
+	constr *Construction
+	round  int
+
+	tracer common.Tracer
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (r round) BlockSize() int { return 16 }
+
+// Encrypt pushes the first block in src through the round and writes the result to dst. Dst and src may point at the
+// same memory.
+func (r round) Encrypt(dst, src []byte) {
+	copy(dst[0:16], src[0:16])
+
+	for pos := 0; pos < 16; pos += 4 {
+		stretched := r.constr.ExpandWord(r.constr.TBoxMixCol[r.round][pos/2:(pos+4)/2], dst[pos:pos+4])
+
+		if r.tracer != nil {
+			r.tracer.OnLookup(r.round, pos+0, "TBoxMixCol", dst[pos+0:pos+2], stretched[0][:])
+			r.tracer.OnLookup(r.round, pos+2, "TBoxMixCol", dst[pos+2:pos+4], stretched[1][:])
+		}
+
+		r.constr.SquashWords(stretched, dst[pos:pos+4])
+	}
+}
+
+// Decrypt is not implemented.
+func (r round) Decrypt(_, _ []byte) {}
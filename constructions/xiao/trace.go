@@ -0,0 +1,38 @@
+package xiao
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// Traced returns a cipher.Block that computes the same thing as constr and tells t about every TBoxMixCol lookup and
+// about the state after the Prologue, each round, and the Epilogue (as rounds -1 through 10).
+func (constr *Construction) Traced(t common.Tracer) cipher.Block {
+	return traced{constr, t}
+}
+
+// traced is a construction with a tracer attached.
+type traced struct {
+	constr *Construction
+	tracer common.Tracer
+}
+
+// BlockSize returns the block size of AES. (Necessary to implement cipher.Block.)
+func (t traced) BlockSize() int { return 16 }
+
+// Encrypt encrypts the first block in src into dst. Dst and src may point at the same memory.
+func (t traced) Encrypt(dst, src []byte) {
+	t.constr.crypt(dst, src, t.tracer)
+}
+
+// Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
+func (t traced) Decrypt(dst, src []byte) {
+	t.constr.crypt(dst, src, t.tracer)
+}
+
+// Faulted returns a cipher.Block that computes constr with the faults f injects. It sees the same lookups and states as
+// a Tracer passed to Traced.
+func (constr *Construction) Faulted(f common.FaultInjector) cipher.Block {
+	return constr.Traced(common.Injecting(f))
+}
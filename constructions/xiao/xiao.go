@@ -12,6 +12,8 @@ package xiao
 import (
 	"github.com/OpenWhiteBox/primitives/matrix"
 	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
 )
 
 type Construction struct {
@@ -26,29 +28,37 @@ func (constr Construction) BlockSize() int { return 16 }
 
 // Encrypt encrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Encrypt(dst, src []byte) {
-	constr.crypt(dst, src)
+	constr.crypt(dst, src, nil)
 }
 
 // Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
 func (constr Construction) Decrypt(dst, src []byte) {
-	constr.crypt(dst, src)
+	constr.crypt(dst, src, nil)
 }
 
-func (constr *Construction) crypt(dst, src []byte) {
+// crypt pushes the first block in src through the construction and writes the result to dst. If t isn't nil, it's told
+// about every lookup and the state after every round.
+func (constr *Construction) crypt(dst, src []byte, t common.Tracer) {
 	copy(dst, src)
 
-	for round := 0; round < 10; round++ {
+	for i := 0; i < 10; i++ {
 		// ShiftRows and re-encoding step.
-		copy(dst, constr.ShiftRows[round].Mul(matrix.Row(dst)))
+		constr.Barrier(i).Encrypt(dst, dst)
+		if i == 0 && t != nil {
+			t.OnState(-1, dst[:16])
+		}
 
 		// Apply T-Boxes and MixColumns
-		for pos := 0; pos < 16; pos += 4 {
-			stretched := constr.ExpandWord(constr.TBoxMixCol[round][pos/2:(pos+4)/2], dst[pos:pos+4])
-			constr.SquashWords(stretched, dst[pos:pos+4])
+		round{constr, i, t}.Encrypt(dst, dst)
+		if t != nil {
+			t.OnState(i, dst[:16])
 		}
 	}
 
-	copy(dst, constr.FinalMask.Mul(matrix.Row(dst)))
+	constr.Epilogue().Encrypt(dst, dst)
+	if t != nil {
+		t.OnState(10, dst[:16])
+	}
 }
 
 func (constr *Construction) ExpandWord(tmc []table.DoubleToWord, word []byte) [2][4]byte {
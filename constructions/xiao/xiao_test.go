@@ -2,12 +2,15 @@ package xiao
 
 import (
 	"bytes"
+	"crypto/aes"
 	"testing"
 
 	"github.com/OpenWhiteBox/primitives/matrix"
 	"github.com/OpenWhiteBox/primitives/table"
 
 	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/internal/tracetest"
+	"github.com/OpenWhiteBox/AES/constructions/network"
 	"github.com/OpenWhiteBox/AES/constructions/saes"
 
 	test_vectors "github.com/OpenWhiteBox/AES/constructions/test"
@@ -99,6 +102,47 @@ func TestDecrypt(t *testing.T) {
 	}
 }
 
+func TestExternalEncodings(t *testing.T) {
+	opts := common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.AffineEncoding, common.AffineEncoding,
+	}
+
+	for n, vec := range test_vectors.GetAESVectors(testing.Short()) {
+		constr, inputMask, outputMask := GenerateEncryptionKeys(vec.Key, vec.Key, opts)
+		inputEnc, outputEnc := common.GenerateExternalEncodings(vec.Key, opts)
+
+		inputInv, _ := inputMask.Invert()
+		outputInv, _ := outputMask.Invert()
+
+		in, out := [16]byte{}, [16]byte{}
+
+		copy(in[:], inputInv.Mul(matrix.Row(vec.In))) // Apply input encoding.
+		in = inputEnc.Encode(in)
+
+		constr.Encrypt(out[:], in[:])
+
+		out = outputEnc.Decode(out) // Remove output encoding.
+		copy(out[:], outputInv.Mul(matrix.Row(out[:])))
+
+		if !bytes.Equal(vec.Out, out[:]) {
+			t.Fatalf("Real disagrees with result in test vector %v! %x != %x", n, vec.Out, out)
+		}
+	}
+}
+
+func TestNonlinearExternalEncodings(t *testing.T) {
+	opts := common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.NoEncoding, common.NonlinearEncoding,
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Generated a construction with nonlinear external encodings!")
+		}
+	}()
+	GenerateDecryptionKeys(key, seed, opts)
+}
+
 func TestPersistence(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping the persistence test in short mode!")
@@ -158,3 +202,146 @@ func BenchmarkDeadEncrypt(b *testing.B) {
 		constr2.Encrypt(out, input)
 	}
 }
+
+func TestBoundEncrypt(t *testing.T) {
+	fingerprint, other := []byte("device-1"), []byte("device-2")
+
+	constr, inputMask, outputMask := GenerateBoundEncryptionKeys(
+		key, seed, fingerprint, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	real := make([]byte, 16)
+	c, _ := aes.NewCipher(key)
+	c.Encrypt(real, input)
+
+	for _, device := range [][]byte{fingerprint, other} {
+		in, out := make([]byte, 16), make([]byte, 16)
+
+		copy(in, inputInv.Mul(matrix.Row(input))) // Apply input encoding.
+
+		common.BoundBlock{constr, device}.Encrypt(out, in)
+
+		copy(out, outputInv.Mul(matrix.Row(out))) // Remove output encoding.
+
+		if same := bytes.Equal(real, out); same != bytes.Equal(device, fingerprint) {
+			t.Fatalf("Bound construction computed the wrong function on device %q! %x, %x", device, real, out)
+		}
+	}
+}
+
+func TestBoundDecrypt(t *testing.T) {
+	fingerprint := []byte("device-1")
+
+	constr, inputMask, outputMask := GenerateBoundDecryptionKeys(
+		key, seed, fingerprint, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	inputInv, _ := inputMask.Invert()
+	outputInv, _ := outputMask.Invert()
+
+	in, out := make([]byte, 16), make([]byte, 16)
+	c, _ := aes.NewCipher(key)
+	c.Encrypt(in, input)
+
+	copy(in, inputInv.Mul(matrix.Row(in))) // Apply input encoding.
+
+	common.BoundBlock{constr, fingerprint}.Decrypt(out, in)
+
+	copy(out, outputInv.Mul(matrix.Row(out))) // Remove output encoding.
+
+	if !bytes.Equal(input, out) {
+		t.Fatalf("Real disagrees with result! %x != %x", input, out)
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the network test in short mode!")
+	}
+
+	constr, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	n, err := network.Parse(constr.Network().Serialize())
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+
+	constr.Encrypt(real, input)
+	n.Encrypt(cand, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with network! %x != %x", real, cand)
+	}
+}
+
+func TestRounds(t *testing.T) {
+	constr, _, _ := GenerateDecryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+	constr.Decrypt(real, input)
+
+	constr.Prologue().Encrypt(cand, input)
+	constr.Round(0).Encrypt(cand, cand)
+	for round := 1; round < 10; round++ {
+		constr.Barrier(round).Encrypt(cand, cand)
+		constr.Round(round).Encrypt(cand, cand)
+	}
+	constr.Epilogue().Encrypt(cand, cand)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with composed rounds! %x != %x", real, cand)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	opts := common.IndependentMasks{common.RandomMask, common.RandomMask}
+	constr, inputMask, outputMask := GenerateEncryptionKeys(key, seed, opts)
+
+	if err := Debug(&constr, key, seed, opts, inputMask, outputMask, input); err != nil {
+		t.Fatalf("Debugger found a divergence in a correct construction: %v", err)
+	}
+
+	// Break one TBoxMixCol table in round 4.
+	constr.TBoxMixCol[4][2] = constr.TBoxMixCol[4][3]
+
+	err, ok := Debug(&constr, key, seed, opts, inputMask, outputMask, input).(*common.Divergence)
+	if !ok {
+		t.Fatalf("Debugger didn't find a divergence in a broken construction!")
+	} else if err.Stage != 5 {
+		t.Fatalf("Debugger found divergence at the wrong stage! %v != 5", err.Stage)
+	}
+}
+
+func TestDebugExternal(t *testing.T) {
+	opts := common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.AffineEncoding, common.AffineEncoding,
+	}
+	constr, inputMask, outputMask := GenerateEncryptionKeys(key, seed, opts)
+
+	if err := Debug(&constr, key, seed, opts, inputMask, outputMask, input); err != nil {
+		t.Fatalf("Debugger found a divergence in a correct construction: %v", err)
+	}
+}
+
+func TestTraced(t *testing.T) {
+	constr, _, _ := GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.RandomMask, common.RandomMask})
+	tracer := &tracetest.Counter{}
+
+	real, cand := make([]byte, 16), make([]byte, 16)
+	constr.Encrypt(real, input)
+	constr.Traced(tracer).Encrypt(cand, input)
+
+	if !bytes.Equal(real, cand) {
+		t.Fatalf("Real disagrees with result! %x != %x", real, cand)
+	} else if tracer.Lookups != 80 {
+		t.Fatalf("Tracer saw the wrong number of lookups! %v != 80", tracer.Lookups)
+	} else if len(tracer.Rounds) != 12 || tracer.Rounds[0] != -1 || tracer.Rounds[11] != 10 {
+		t.Fatalf("Tracer saw the state after the wrong rounds! %v", tracer.Rounds)
+	} else if !bytes.Equal(tracer.Last[:16], cand) {
+		t.Fatalf("Tracer's last state isn't the output! %x != %x", tracer.Last, cand)
+	}
+}
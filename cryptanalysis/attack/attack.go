@@ -0,0 +1,91 @@
+// Package attack puts the key-recovery attacks in this repository behind one interface, keeps a registry of which
+// attacks apply to which constructions, and measures how reliably and how quickly each attack succeeds on freshly
+// generated instances.
+package attack
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sort"
+)
+
+// ErrNotApplicable is returned by an attack given a construction it doesn't apply to.
+var ErrNotApplicable = errors.New("Attack doesn't apply to this construction!")
+
+// Attack recovers the AES key a white-box construction was generated with.
+type Attack interface {
+	// Name identifies the attack in reports.
+	Name() string
+
+	// RecoverKey returns the key constr was generated with, or an error if it couldn't find one.
+	RecoverKey(constr cipher.Block) ([]byte, error)
+}
+
+// Func is an attack implemented by a function.
+type Func struct {
+	Label   string
+	Recover func(constr cipher.Block) ([]byte, error)
+}
+
+// Name implements Attack.
+func (f Func) Name() string { return f.Label }
+
+// RecoverKey implements Attack.
+func (f Func) RecoverKey(constr cipher.Block) ([]byte, error) { return f.Recover(constr) }
+
+// Target is a construction that attacks can be run against.
+type Target struct {
+	Name string
+
+	// Generate returns a new instance of the construction with the given key and seed.
+	Generate func(key, seed []byte) cipher.Block
+
+	// Attacks are the attacks that apply to the instances Generate returns.
+	Attacks []Attack
+}
+
+// Attack returns the attack on the construction with the given name.
+func (t Target) Attack(name string) (Attack, bool) {
+	for _, attack := range t.Attacks {
+		if attack.Name() == name {
+			return attack, true
+		}
+	}
+
+	return nil, false
+}
+
+var registry = map[string]*Target{}
+
+// Register adds a construction to the registry. If a construction with the same name is already registered, the attacks
+// are added to it and generate is ignored.
+func Register(name string, generate func(key, seed []byte) cipher.Block, attacks ...Attack) {
+	if target, ok := registry[name]; ok {
+		target.Attacks = append(target.Attacks, attacks...)
+		return
+	}
+
+	registry[name] = &Target{name, generate, attacks}
+}
+
+// Lookup returns the registered construction with the given name.
+func Lookup(name string) (Target, bool) {
+	target, ok := registry[name]
+	if !ok {
+		return Target{}, false
+	}
+
+	return *target, true
+}
+
+// Targets returns every registered construction, sorted by name.
+func Targets() []Target {
+	out := make([]Target, 0, len(registry))
+	for _, target := range registry {
+		out = append(out, *target)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
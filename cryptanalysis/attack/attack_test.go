@@ -0,0 +1,82 @@
+package attack
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"chow", "chow-masked", "chow-encoded", "xiao", "xiao-masked", "xiao-affine", "toy"} {
+		target, ok := Lookup(name)
+		if !ok {
+			t.Fatalf("%v isn't registered!", name)
+		}
+
+		// Every attack should refuse a construction it doesn't apply to.
+		for _, attack := range target.Attacks {
+			if _, err := attack.RecoverKey(nil); err != ErrNotApplicable {
+				t.Fatalf("%v/%v accepted the wrong construction: %v", name, attack.Name(), err)
+			}
+		}
+	}
+
+	if targets := Targets(); len(targets) < 3 || targets[0].Name > targets[1].Name {
+		t.Fatalf("Targets returned the wrong constructions! %v", targets)
+	}
+}
+
+func TestRun(t *testing.T) {
+	chow, _ := Lookup("chow")
+	collisions, ok := chow.Attack("collisions")
+	if !ok {
+		t.Fatal("chow doesn't have the collision attack!")
+	}
+
+	target := Target{
+		Name:     "chow",
+		Generate: chow.Generate,
+		Attacks: []Attack{
+			collisions,
+			Func{"wrong", func(_ cipher.Block) ([]byte, error) { return make([]byte, 16), nil }},
+			Func{"panic", func(_ cipher.Block) ([]byte, error) { panic("oops") }},
+		},
+	}
+
+	results := Run([]Target{target}, 2, 1)
+	if len(results) != 3 {
+		t.Fatalf("Wrong number of results! %v != 3", len(results))
+	}
+
+	for i, successes := range []int{2, 0, 0} {
+		if results[i].Runs != 2 || results[i].Successes != successes {
+			t.Fatalf("%v succeeded %v/%v times, not %v/2!", results[i].Attack, results[i].Successes, results[i].Runs, successes)
+		}
+	}
+
+	if results[0].Mean <= 0 || results[0].P99 < results[0].Median {
+		t.Fatalf("Wrong runtimes! %#v", results[0])
+	} else if results[0].PeakMemory == 0 {
+		t.Fatalf("Memory wasn't measured! %#v", results[0])
+	} else if !strings.Contains(results[2].Error, "oops") {
+		t.Fatalf("Panic wasn't reported! %q", results[2].Error)
+	}
+
+	table := &bytes.Buffer{}
+	if err := WriteTable(table, results); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(table.String(), "collisions") {
+		t.Fatalf("Table is missing an attack!\n%v", table)
+	}
+
+	buff, parsed := &bytes.Buffer{}, []Result{}
+	if err := WriteJSON(buff, results); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(buff.Bytes(), &parsed); err != nil {
+		t.Fatal(err)
+	} else if parsed[0] != results[0] {
+		t.Fatalf("JSON disagrees with results! %#v != %#v", parsed[0], results[0])
+	}
+}
@@ -0,0 +1,208 @@
+package attack
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Result is how an attack did against a construction over a number of runs.
+type Result struct {
+	Construction string `json:"construction"`
+	Attack       string `json:"attack"`
+
+	Runs      int `json:"runs"`
+	Successes int `json:"successes"`
+
+	// Mean, Median, P90, and P99 are statistics of the runtimes of every run, successful or not.
+	Mean   time.Duration `json:"mean_ns"`
+	Median time.Duration `json:"median_ns"`
+	P90    time.Duration `json:"p90_ns"`
+	P99    time.Duration `json:"p99_ns"`
+
+	// PeakMemory is the largest growth of the heap, in bytes, seen during any run. The heap is only sampled every
+	// sampleInterval, so short-lived allocations between samples can be missed.
+	PeakMemory uint64 `json:"peak_memory"`
+
+	// Error is the first error an attack returned, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// SuccessRate is the fraction of runs that recovered the right key.
+func (r Result) SuccessRate() float64 {
+	if r.Runs == 0 {
+		return 0
+	}
+
+	return float64(r.Successes) / float64(r.Runs)
+}
+
+// Run runs every attack on each target against n instances of it, generated from random keys and seeds drawn from a
+// source seeded with seed. Every attack on a target sees the same instances. A run succeeds if the attack returns the
+// key the instance was generated with; errors, wrong keys, and panics are failures. Each attack runs once on each
+// instance, and is timed and has its memory measured in the same run.
+func Run(targets []Target, n int, seed int64) []Result {
+	r := rand.New(rand.NewSource(seed))
+	out := []Result{}
+
+	for _, target := range targets {
+		results := make([]Result, len(target.Attacks))
+		runtimes := make([][]time.Duration, len(target.Attacks))
+
+		for i, attack := range target.Attacks {
+			results[i] = Result{Construction: target.Name, Attack: attack.Name(), Runs: n}
+		}
+
+		for run := 0; run < n; run++ {
+			key, constrSeed := make([]byte, 16), make([]byte, 16)
+			r.Read(key)
+			r.Read(constrSeed)
+
+			constr := target.Generate(key, constrSeed)
+
+			for i, attack := range target.Attacks {
+				cand, runtime, memory, err := measure(attack, constr)
+				runtimes[i] = append(runtimes[i], runtime)
+
+				if memory > results[i].PeakMemory {
+					results[i].PeakMemory = memory
+				}
+
+				if err == nil && bytes.Equal(cand, key) {
+					results[i].Successes++
+				} else if err != nil && results[i].Error == "" {
+					results[i].Error = err.Error()
+				}
+			}
+		}
+
+		for i := range results {
+			results[i].Mean, results[i].Median = mean(runtimes[i]), percentile(runtimes[i], 0.5)
+			results[i].P90, results[i].P99 = percentile(runtimes[i], 0.9), percentile(runtimes[i], 0.99)
+		}
+
+		out = append(out, results...)
+	}
+
+	return out
+}
+
+// recoverKey runs an attack, recovering from any panic.
+func recoverKey(attack Attack, constr cipher.Block) (key []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			key, err = nil, fmt.Errorf("Attack panicked: %v", r)
+		}
+	}()
+
+	return attack.RecoverKey(constr)
+}
+
+// sampleInterval is how often measure reads the heap's size while an attack runs. Reading it stops the world, so it's
+// kept coarse enough that it barely slows the attack down.
+const sampleInterval = 10 * time.Millisecond
+
+// measure runs an attack and returns its result, how long it took, and how much the heap grew at most while it ran. The
+// heap is sampled every sampleInterval and once more when the attack finishes.
+func measure(attack Attack, constr cipher.Block) (key []byte, elapsed time.Duration, memory uint64, err error) {
+	stats := runtime.MemStats{}
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+
+	done, sampled := make(chan struct{}), make(chan uint64)
+	go func() {
+		max, ticker := uint64(0), time.NewTicker(sampleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+			case <-ticker.C:
+			}
+
+			stats := runtime.MemStats{}
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > base && stats.HeapAlloc-base > max {
+				max = stats.HeapAlloc - base
+			}
+
+			select {
+			case <-done:
+				sampled <- max
+				return
+			default:
+			}
+		}
+	}()
+
+	start := time.Now()
+	key, err = recoverKey(attack, constr)
+	elapsed = time.Since(start)
+
+	close(done)
+	memory = <-sampled
+
+	return
+}
+
+func mean(xs []time.Duration) time.Duration {
+	if len(xs) == 0 {
+		return 0
+	}
+
+	total := time.Duration(0)
+	for _, x := range xs {
+		total += x
+	}
+
+	return total / time.Duration(len(xs))
+}
+
+// percentile returns the p-th quantile of xs by the nearest-rank method.
+func percentile(xs []time.Duration, p float64) time.Duration {
+	if len(xs) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration{}, xs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+// WriteTable writes results to w as a human-readable table.
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONSTRUCTION\tATTACK\tSUCCESS\tMEAN\tMEDIAN\tP90\tP99\tPEAK MEMORY\tERROR")
+
+	for _, r := range results {
+		fmt.Fprintf(tw, "%v\t%v\t%v/%v (%.0f%%)\t%v\t%v\t%v\t%v\t%.1f MiB\t%v\n",
+			r.Construction, r.Attack, r.Successes, r.Runs, 100*r.SuccessRate(),
+			r.Mean, r.Median, r.P90, r.P99, float64(r.PeakMemory)/(1<<20), r.Error,
+		)
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes results to w as a JSON array.
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(results)
+}
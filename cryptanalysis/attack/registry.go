@@ -0,0 +1,112 @@
+package attack
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/toy"
+	"github.com/OpenWhiteBox/AES/constructions/xiao"
+
+	achow "github.com/OpenWhiteBox/AES/cryptanalysis/chow"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/lda"
+	atoy "github.com/OpenWhiteBox/AES/cryptanalysis/toy"
+	axiao "github.com/OpenWhiteBox/AES/cryptanalysis/xiao"
+)
+
+// noInputMask is the key generation options of registered constructions that take any. Attacks that need the
+// plaintext, like LDA and the collision attack, don't work through an input mask.
+var noInputMask = common.IndependentMasks{common.IdentityMask, common.RandomMask}
+
+// masked is the key generation options of registered constructions with random input and output masks. Only attacks
+// that never look at the plaintext, like the SAS attacks, see through them.
+var masked = common.IndependentMasks{common.RandomMask, common.RandomMask}
+
+// encoded is the key generation options of registered constructions with external encodings on top of their masks.
+func encoded(enc common.EncodingType) common.KeyGenerationOpts {
+	return common.ExternalEncodings{masked, enc, enc}
+}
+
+var (
+	chowSAS = Func{"sas", func(constr cipher.Block) ([]byte, error) {
+		if constr, ok := constr.(*chow.Construction); ok {
+			return achow.RecoverKeyContext(context.Background(), constr, nil)
+		}
+		return nil, ErrNotApplicable
+	}}
+
+	xiaoSAS = Func{"sas", func(constr cipher.Block) ([]byte, error) {
+		if constr, ok := constr.(*xiao.Construction); ok {
+			return axiao.RecoverKeyContext(context.Background(), constr, nil)
+		}
+		return nil, ErrNotApplicable
+	}}
+)
+
+func init() {
+	Register("chow", func(key, seed []byte) cipher.Block {
+		constr, _, _ := chow.GenerateEncryptionKeys(key, seed, noInputMask)
+		return &constr
+	},
+		chowSAS,
+		Func{"collisions", func(constr cipher.Block) ([]byte, error) {
+			if constr, ok := constr.(*chow.Construction); ok {
+				return achow.RecoverKeyByCollisions(constr)
+			}
+			return nil, ErrNotApplicable
+		}},
+		Func{"lda", func(constr cipher.Block) ([]byte, error) {
+			if constr, ok := constr.(*chow.Construction); ok {
+				return lda.RecoverKey(constr, lda.Chow, rand.Reader)
+			}
+			return nil, ErrNotApplicable
+		}},
+	)
+
+	Register("xiao", func(key, seed []byte) cipher.Block {
+		constr, _, _ := xiao.GenerateEncryptionKeys(key, seed, noInputMask)
+		return &constr
+	},
+		xiaoSAS,
+		Func{"lda", func(constr cipher.Block) ([]byte, error) {
+			if constr, ok := constr.(*xiao.Construction); ok {
+				return lda.RecoverKey(constr, lda.Xiao, rand.Reader)
+			}
+			return nil, ErrNotApplicable
+		}},
+	)
+
+	Register("chow-masked", func(key, seed []byte) cipher.Block {
+		constr, _, _ := chow.GenerateEncryptionKeys(key, seed, masked)
+		return &constr
+	}, chowSAS)
+
+	Register("xiao-masked", func(key, seed []byte) cipher.Block {
+		constr, _, _ := xiao.GenerateEncryptionKeys(key, seed, masked)
+		return &constr
+	}, xiaoSAS)
+
+	Register("chow-encoded", func(key, seed []byte) cipher.Block {
+		constr, _, _ := chow.GenerateEncryptionKeys(key, seed, encoded(common.NonlinearEncoding))
+		return &constr
+	}, chowSAS)
+
+	Register("xiao-affine", func(key, seed []byte) cipher.Block {
+		constr, _, _ := xiao.GenerateEncryptionKeys(key, seed, encoded(common.AffineEncoding))
+		return &constr
+	}, xiaoSAS)
+
+	Register("toy", func(key, seed []byte) cipher.Block {
+		constr, _, _ := toy.GenerateKeys(key, seed)
+		return &constr
+	},
+		Func{"parasites", func(constr cipher.Block) ([]byte, error) {
+			if constr, ok := constr.(*toy.Construction); ok {
+				return atoy.RecoverKeyContext(context.Background(), constr, nil)
+			}
+			return nil, ErrNotApplicable
+		}},
+	)
+}
@@ -6,38 +6,22 @@
 package chow
 
 import (
+	"context"
+	"crypto/cipher"
+	"errors"
+	"sync"
+
 	"github.com/OpenWhiteBox/primitives/encoding"
 
 	"github.com/OpenWhiteBox/AES/constructions/chow"
 	"github.com/OpenWhiteBox/AES/constructions/common"
-	"github.com/OpenWhiteBox/AES/constructions/saes"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/keyschedule"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/progress"
 
 	cspn "github.com/OpenWhiteBox/Generic/constructions/spn"
 	aspn "github.com/OpenWhiteBox/Generic/cryptanalysis/spn"
 )
 
-var powx = [16]byte{0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x1b, 0x36, 0x6c, 0xd8, 0xab, 0x4d, 0x9a, 0x2f}
-
-// backOneRound takes round key i and returns round key i-1.
-func backOneRound(roundKey []byte, round int) (out []byte) {
-	out = make([]byte, 16)
-	constr := saes.Construction{}
-
-	// Recover everything except the first word by XORing consecutive blocks.
-	for pos := 4; pos < 16; pos++ {
-		out[pos] = roundKey[pos] ^ roundKey[pos-4]
-	}
-
-	// Recover the first word by XORing the first block of the roundKey with f(last block of roundKey), where f is a
-	// subroutine of AES' key scheduling algorithm.
-	for pos := 0; pos < 4; pos++ {
-		out[pos] = roundKey[pos] ^ constr.SubByte(out[12+(pos+1)%4])
-	}
-	out[0] ^= powx[round-1]
-
-	return
-}
-
 // isAS returns true if the given Byte encoding might be an AS structure, with 2 4-bit S-boxes.
 func isAS(in encoding.Byte) bool {
 	temp1, temp2 := byte(0x00), byte(0x00)
@@ -50,37 +34,100 @@ func isAS(in encoding.Byte) bool {
 	return temp1 == 0 && temp2 == 0
 }
 
-// round isolates one round of encryption with an AES white-box.
-type round struct {
-	construction *chow.Construction
-	round        int
-}
+// extractKey finds the round key that leading hides by guessing each byte until the S-box is only an AS structure away
+// from it. Each byte is guessed in its own goroutine.
+func extractKey(ctx context.Context, leading sboxLayer, report progress.Func) (key [16]byte, err error) {
+	var (
+		found = [16]bool{}
+
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done = 0
+	)
 
-func (r round) Encrypt(dst, src []byte) {
-	copy(dst[0:16], src[0:16])
+	report.Report(progress.KeyExtraction, 0, 16)
+	for pos := 0; pos < 16; pos++ {
+		wg.Add(1)
+		go func(pos int) {
+			defer wg.Done()
+
+			for guess := 0; guess < 256 && ctx.Err() == nil; guess++ {
+				cand := encoding.ComposedBytes{
+					leading[pos], encoding.ByteAdditive(guess), encoding.InverseByte{sbox{}},
+				}
+
+				if isAS(cand) {
+					key[pos], found[pos] = byte(guess), true
+					break
+				}
+			}
 
-	for pos := 0; pos < 16; pos += 4 {
-		stretched := r.construction.ExpandWord(r.construction.TBoxTyiTable[r.round][pos:pos+4], dst[pos:pos+4])
-		r.construction.SquashWords(r.construction.HighXORTable[r.round][2*pos:2*pos+8], stretched, dst[pos:pos+4])
+			mu.Lock()
+			done++
+			report.Report(progress.KeyExtraction, done, 16)
+			mu.Unlock()
+		}(pos)
+	}
+	wg.Wait()
 
-		stretched = r.construction.ExpandWord(r.construction.MBInverseTable[r.round][pos:pos+4], dst[pos:pos+4])
-		r.construction.SquashWords(r.construction.LowXORTable[r.round][2*pos:2*pos+8], stretched, dst[pos:pos+4])
+	if err := ctx.Err(); err != nil {
+		return key, err
 	}
+	for pos := 0; pos < 16; pos++ {
+		if !found[pos] {
+			return key, errors.New("Key extraction failed!")
+		}
+	}
+
+	return key, nil
 }
 
 // RecoverKey returns the AES key used to generate the given white-box construction.
 func RecoverKey(constr *chow.Construction) []byte {
-	round1, round2 := round{
-		construction: constr,
-		round:        1,
-	}, round{
-		construction: constr,
-		round:        2,
+	key, _ := RecoverKeyContext(context.Background(), constr, nil)
+	return key
+}
+
+// RecoverKeyContext is RecoverKey, but stops with ctx's error when ctx is done and reports its progress to report, which
+// may be nil. It's checked for cancellation before and between the decompositions and between guesses of a key byte,
+// and report is never called concurrently. The decompositions themselves can't be interrupted, and they take most of
+// the attack's time, so cancelling it can take as long as one of them.
+func RecoverKeyContext(ctx context.Context, constr *chow.Construction, report progress.Func) ([]byte, error) {
+	return RecoverKeyFromRound(ctx, constr, 1, report)
+}
+
+// Rounds is the part of a construction that the attack uses. *chow.Construction implements it, but so can a partial
+// construction that only has some rounds' tables.
+type Rounds interface {
+	Round(i int) cipher.Block
+}
+
+// RecoverKeyFromRound is RecoverKeyContext, but attacks the given round and the one after it, for 0 <= round < 8,
+// instead of rounds 1 and 2. It only uses Round(round) and Round(round+1), so constr may be missing every other round.
+// External encodings are merged into the Prologue and Epilogue, never the rounds, so they don't affect it.
+func RecoverKeyFromRound(ctx context.Context, constr Rounds, round int, report progress.Func) ([]byte, error) {
+	if round < 0 || round >= 8 {
+		return nil, errors.New("Round is out of range!")
+	}
+	round1, round2 := constr.Round(round), constr.Round(round+1)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	// Decomposition Phase
+	report.Report(progress.Decomposition, 0, 2)
 	constr1 := aspn.DecomposeSPN(round1, cspn.SAS)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report.Report(progress.Decomposition, 1, 2)
 	constr2 := aspn.DecomposeSPN(round2, cspn.SAS)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	report.Report(progress.Decomposition, 2, 2)
 
 	var (
 		leading, middle, trailing sboxLayer
@@ -97,6 +144,8 @@ func RecoverKey(constr *chow.Construction) []byte {
 	}
 
 	// Disambiguation Phase
+	report.Report(progress.Disambiguation, 0, 1)
+
 	// Disambiguate the affine layer.
 	lin, lout := left.clean()
 	rin, rout := right.clean()
@@ -127,23 +176,16 @@ func RecoverKey(constr *chow.Construction) []byte {
 	// ))
 	// Output: true
 
-	// Extract the key from the leading S-boxes.
-	key := [16]byte{}
+	report.Report(progress.Disambiguation, 1, 1)
 
-	for pos := 0; pos < 16; pos++ {
-		for guess := 0; guess < 256; guess++ {
-			cand := encoding.ComposedBytes{
-				leading[pos], encoding.ByteAdditive(guess), encoding.InverseByte{sbox{}},
-			}
-
-			if isAS(cand) {
-				key[pos] = byte(guess)
-				break
-			}
-		}
+	// Extract the key from the leading S-boxes.
+	key, err := extractKey(ctx, leading, report)
+	if err != nil {
+		return nil, err
 	}
 
 	key = left.Encode(key)
 
-	return backOneRound(backOneRound(key[:], 2), 1)
+	// The key between the two rounds is the one that's extracted.
+	return keyschedule.RecoverKey(16, round+1, key[:])
 }
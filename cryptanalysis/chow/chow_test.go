@@ -4,10 +4,17 @@ import (
 	"testing"
 
 	"bytes"
+	"context"
+	"crypto/cipher"
 	"crypto/rand"
 
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/number"
+
 	"github.com/OpenWhiteBox/AES/constructions/chow"
 	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/internal/benchtest"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/progress"
 )
 
 func TestRecoverKey(t *testing.T) {
@@ -25,6 +32,197 @@ func TestRecoverKey(t *testing.T) {
 	}
 }
 
+func TestRecoverKeyContext(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := chow.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	// A context that's already done stops the attack before it starts.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cand, err := RecoverKeyContext(ctx, &constr, func(_ progress.Phase, _, _ int) {
+		t.Fatal("Cancelled attack reported progress!")
+	})
+	if err != context.Canceled || cand != nil {
+		t.Fatalf("Attack wasn't cancelled! %x, %v", cand, err)
+	}
+
+	// Otherwise, the attack stops once the decomposition it's in finishes.
+	ctx, cancel = context.WithCancel(context.Background())
+	phases := []progress.Phase{}
+
+	cand, err = RecoverKeyContext(ctx, &constr, func(phase progress.Phase, done, total int) {
+		if len(phases) == 0 || phases[len(phases)-1] != phase {
+			phases = append(phases, phase)
+		}
+		cancel()
+	})
+
+	if err != context.Canceled || cand != nil {
+		t.Fatalf("Attack wasn't cancelled! %x, %v", cand, err)
+	} else if len(phases) != 1 || phases[0] != progress.Decomposition {
+		t.Fatalf("Wrong phases reported! %v", phases)
+	}
+}
+
+func TestRecoverKeyExternal(t *testing.T) {
+	for _, enc := range []common.EncodingType{common.AffineEncoding, common.NonlinearEncoding} {
+		key := make([]byte, 16)
+		rand.Read(key)
+
+		constr, _, _ := chow.GenerateEncryptionKeys(key, key, common.ExternalEncodings{
+			common.IndependentMasks{common.RandomMask, common.RandomMask}, enc, enc,
+		})
+
+		cand := RecoverKey(&constr)
+
+		if !bytes.Equal(cand, key) {
+			t.Fatalf("Recovered wrong key with external encoding %v!\nreal=%x\ncand=%x", enc, key, cand)
+		}
+	}
+}
+
+// leadingLayer returns the leading S-boxes of a decomposition of a construction with the given round key.
+func leadingLayer(key []byte) (out sboxLayer) {
+	for pos := 0; pos < 16; pos++ {
+		out[pos] = encoding.ComposedBytes{sbox{}, encoding.ByteAdditive(key[pos])}
+	}
+
+	return
+}
+
+func TestCleanMiddle(t *testing.T) {
+	// The middle S-boxes are AES's, under a random constant and a random multiplication on each side. The multiplications
+	// are the same across a column of the input, and across a column of the output after ShiftRows.
+	middle := sboxLayer{}
+	constants, mults := make([]byte, 32), make([]byte, 8)
+	rand.Read(constants)
+	rand.Read(mults)
+
+	for pos := 0; pos < 16; pos++ {
+		a, c := mults[2*(pos/4)]|1, mults[2*(common.ShiftRows(pos)/4)+1]|1
+
+		middle[pos] = encoding.ComposedBytes{
+			encoding.ByteAdditive(constants[2*pos]),
+			encoding.NewByteMultiplication(number.ByteFieldElem(a)),
+			sbox{},
+			encoding.NewByteMultiplication(number.ByteFieldElem(c)),
+			encoding.ByteAdditive(constants[2*pos+1]),
+		}
+	}
+
+	middle.cleanConstant()
+	middle.cleanLinear()
+
+	// What's left is AES's S-box, without the 0x63 constant.
+	real := encoding.ComposedBytes{sbox{}, encoding.ByteAdditive(0x63)}
+	for pos := 0; pos < 16; pos++ {
+		if !encoding.EquivalentBytes(real, middle[pos]) {
+			t.Fatalf("S-box %v wasn't cleaned!", pos)
+		}
+	}
+}
+
+func TestExtractKey(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	cand, err := extractKey(context.Background(), leadingLayer(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(cand[:], key) {
+		t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, cand)
+	}
+}
+
+// partial is a construction that only ships some of its rounds.
+type partial map[int]cipher.Block
+
+func (p partial) Round(i int) cipher.Block {
+	if round, ok := p[i]; ok {
+		return round
+	}
+
+	panic("round was stripped")
+}
+
+func TestRecoverKeyFromRound(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := chow.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	for _, round := range []int{0, 4, 7} {
+		stripped := partial{round: constr.Round(round), round + 1: constr.Round(round + 1)}
+
+		cand, err := RecoverKeyFromRound(context.Background(), stripped, round, nil)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(cand, key) {
+			t.Fatalf("Recovered wrong key from round %v!\nreal=%x\ncand=%x", round, key, cand)
+		}
+	}
+}
+
+func TestRecoverKeyByCollisions(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := chow.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.IdentityMask, common.RandomMask},
+	)
+
+	cand, err := RecoverKeyByCollisions(&constr)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(cand, key) {
+		t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, cand)
+	}
+}
+
+func TestRecoverKeyByCollisionsExternal(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := chow.GenerateEncryptionKeys(key, key, common.ExternalEncodings{
+		common.IndependentMasks{common.IdentityMask, common.RandomMask}, common.NonlinearEncoding, common.NoEncoding,
+	})
+
+	if _, err := RecoverKeyByCollisions(&constr); err == nil {
+		t.Fatal("Recovered a key through a nonlinear external encoding!")
+	}
+}
+
+func BenchmarkRecoverKey(b *testing.B) {
+	key := make([]byte, 16)
+	constr, _, _ := chow.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.IdentityMask, common.RandomMask},
+	)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		RecoverKey(&constr)
+	}
+}
+
+func BenchmarkRecoverKeyByCollisions(b *testing.B) {
+	key := make([]byte, 16)
+	constr, _, _ := chow.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.IdentityMask, common.RandomMask},
+	)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		RecoverKeyByCollisions(&constr)
+	}
+}
+
 // func TestMakeConstants(t *testing.T) {
 //   MC := gfmatrix.Matrix{
 //     gfmatrix.Row{2, 3, 1, 1},
@@ -83,3 +281,77 @@ func TestRecoverKey(t *testing.T) {
 //   }
 //   fmt.Println("}")
 // }
+
+func BenchmarkExtractKey(b *testing.B) {
+	key := bytes.Repeat([]byte{0xff}, 16)
+	leading := leadingLayer(key)
+
+	benchtest.Procs(b, func() { extractKey(context.Background(), leading, nil) })
+}
+
+func TestInvert(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := chow.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	// Only the serialized tables are public.
+	parsed, err := chow.Parse(constr.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inverted, err := Invert(&parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, output, cand := make([]byte, 16), make([]byte, 16), make([]byte, 16)
+	rand.Read(input)
+
+	inverted.Encrypt(output, input)
+	inverted.Decrypt(cand, output)
+
+	if !bytes.Equal(input, cand) {
+		t.Fatalf("Decryption disagrees with encryption! %x != %x", input, cand)
+	}
+}
+
+func TestInvertExternal(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	// Affine external encodings are inverted along with the masks.
+	constr, _, _ := chow.GenerateEncryptionKeys(key, key, common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.AffineEncoding, common.AffineEncoding,
+	})
+
+	inverted, err := Invert(&constr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, output, cand := make([]byte, 16), make([]byte, 16), make([]byte, 16)
+	rand.Read(input)
+
+	inverted.Encrypt(output, input)
+	inverted.Decrypt(cand, output)
+
+	if !bytes.Equal(input, cand) {
+		t.Fatalf("Decryption disagrees with encryption! %x != %x", input, cand)
+	}
+
+	// Nonlinear ones aren't, and have to be refused.
+	for _, opts := range []common.ExternalEncodings{
+		{common.IndependentMasks{common.RandomMask, common.RandomMask}, common.NonlinearEncoding, common.NoEncoding},
+		{common.IndependentMasks{common.RandomMask, common.RandomMask}, common.NoEncoding, common.NonlinearEncoding},
+	} {
+		constr, _, _ := chow.GenerateEncryptionKeys(key, key, opts)
+
+		if _, err := Invert(&constr); err == nil {
+			t.Fatalf("Inverted a construction with nonlinear external encodings %v/%v!", opts.Input, opts.Output)
+		}
+	}
+}
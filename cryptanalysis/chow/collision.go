@@ -0,0 +1,116 @@
+package chow
+
+import (
+	"errors"
+
+	"github.com/OpenWhiteBox/primitives/number"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// firstRound computes the first round of constr on a block that's zero except for byte pos of the shifted state, and
+// returns the encoded first byte of that byte's column.
+func firstRound(constr *chow.Construction, pos int, x byte) byte {
+	block := make([]byte, 16)
+	block[common.UnShiftRows(pos)] = x
+
+	aes := saes.Construction{}
+	constr.Prologue().Encrypt(block, block)
+	aes.ShiftRows(block)
+	constr.Round(0).Encrypt(block, block)
+
+	return block[pos/4*4]
+}
+
+// collisions returns, for a column of the first round and two of its rows i and j, the pairs (a, b) such that setting
+// row i of the column to a gives the same first output byte as setting row j to b. Each is an equation
+//
+//	c_i * (S(a ^ k_i) ^ S(k_i)) = c_j * (S(b ^ k_j) ^ S(k_j))
+//
+// where c_i and c_j are entries i and j of the first row of MixColumns and k_i and k_j are the key bytes.
+func collisions(constr *chow.Construction, col, i, j int) (out [][2]byte) {
+	seen := make(map[byte]byte)
+	for b := 0; b < 256; b++ {
+		seen[firstRound(constr, 4*col+j, byte(b))] = byte(b)
+	}
+
+	for a := 1; a < 256; a++ {
+		if b, ok := seen[firstRound(constr, 4*col+i, byte(a))]; ok {
+			out = append(out, [2]byte{byte(a), b})
+		}
+	}
+
+	return
+}
+
+// satisfies returns true if the key bytes ki and kj satisfy every equation from collisions, for rows i and j.
+func satisfies(equations [][2]byte, i, j int, ki, kj byte) bool {
+	coeffs := [4]number.ByteFieldElem{0x02, 0x03, 0x01, 0x01}
+
+	for _, eq := range equations {
+		left := coeffs[i].Mul(number.ByteFieldElem(saes.SBox[eq[0]^ki] ^ saes.SBox[ki]))
+		right := coeffs[j].Mul(number.ByteFieldElem(saes.SBox[eq[1]^kj] ^ saes.SBox[kj]))
+
+		if left != right {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RecoverKeyByCollisions returns the AES key used to generate the given encryption construction, which must have no
+// input mask. For each column of the first round, it varies one input byte at a time and finds pairs of inputs that
+// collide in the encoded first byte of the round's output. Each collision is an equation in two key bytes of the column,
+// because the encodings are bijective. The first two key bytes are found by exhaustive search over 2^16 candidates and
+// the others over 2^8 each.
+//
+// External encodings on the input break the attack. A nonlinear one leaves the equations without a solution, and an
+// error is returned. An affine one shifts every key byte by its constant, which the collisions can't tell apart from
+// the key, so the key that's returned is off by it.
+//
+// "Two Attacks on a White-Box AES Implementation" by Tancrède Lepoint, Matthieu Rivain, Yoni De Mulder, Peter Roelse,
+// and Bart Preneel, https://eprint.iacr.org/2013/455.pdf
+func RecoverKeyByCollisions(constr *chow.Construction) ([]byte, error) {
+	key := make([]byte, 16)
+
+	for col := 0; col < 4; col++ {
+		column := [4]byte{}
+
+		// Recover the first two key bytes of the column together.
+		eqs, found := collisions(constr, col, 0, 1), 0
+		for k0 := 0; k0 < 256; k0++ {
+			for k1 := 0; k1 < 256; k1++ {
+				if satisfies(eqs, 0, 1, byte(k0), byte(k1)) {
+					column[0], column[1] = byte(k0), byte(k1)
+					found++
+				}
+			}
+		}
+		if found != 1 {
+			return nil, errors.New("Collisions didn't determine the key!")
+		}
+
+		// Recover the rest given the first.
+		for j := 2; j < 4; j++ {
+			eqs, found = collisions(constr, col, 0, j), 0
+			for kj := 0; kj < 256; kj++ {
+				if satisfies(eqs, 0, j, column[0], byte(kj)) {
+					column[j] = byte(kj)
+					found++
+				}
+			}
+			if found != 1 {
+				return nil, errors.New("Collisions didn't determine the key!")
+			}
+		}
+
+		for i := 0; i < 4; i++ {
+			key[common.UnShiftRows(4*col+i)] = column[i]
+		}
+	}
+
+	return key, nil
+}
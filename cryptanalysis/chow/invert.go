@@ -0,0 +1,331 @@
+package chow
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"math/rand"
+	"sort"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+	"github.com/OpenWhiteBox/primitives/table"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// nibble returns the n-th nibble of in, counting from the high nibble of the first byte.
+func nibble(in []byte, n int) byte {
+	if n%2 == 0 {
+		return in[n/2] >> 4
+	}
+
+	return in[n/2] & 0x0f
+}
+
+// setNibble sets the n-th nibble of out to x.
+func setNibble(out []byte, n int, x byte) {
+	if n%2 == 0 {
+		out[n/2] = out[n/2]&0x0f | x<<4
+	} else {
+		out[n/2] = out[n/2]&0xf0 | x
+	}
+}
+
+// encodedLinear inverts a layer that's an affine transformation followed by a nibble-wise encoding, like the
+// Prologue. Each nibble's encoding is learned up to a linear transformation, which the inverse of the layer's matrix
+// makes up for.
+type encodedLinear struct {
+	decode  [32][16]byte // decode[n][x] is the n-th nibble of the affine part's output, when the layer's is x.
+	inverse matrix.Matrix
+}
+
+func newEncodedLinear(layer cipher.Block) (out encodedLinear, err error) {
+	eval := func(in []byte) []byte {
+		res := make([]byte, 16)
+		layer.Encrypt(res, in)
+		return res
+	}
+	zero := eval(make([]byte, 16))
+	r := rand.New(rand.NewSource(0))
+
+	// Find four inputs for each nibble whose outputs generate it as a vector space, taking the output on zero as zero.
+	// The output on a sum of inputs is the sum of their outputs.
+	for n := 0; n < 32; n++ {
+		known, inputs := map[byte]bool{nibble(zero, n): true}, [][]byte{make([]byte, 16)}
+
+		for len(inputs) < 16 {
+			cand := make([]byte, 16)
+			r.Read(cand)
+			if known[nibble(eval(cand), n)] {
+				continue
+			}
+
+			for label := range inputs {
+				in := make([]byte, 16)
+				for i := range in {
+					in[i] = inputs[label][i] ^ cand[i]
+				}
+
+				x := nibble(eval(in), n)
+				if known[x] {
+					return out, errors.New("Layer isn't an encoded affine transformation!")
+				}
+
+				known[x], inputs = true, append(inputs, in)
+				out.decode[n][x] = byte(len(inputs) - 1)
+			}
+		}
+	}
+
+	// The decoded layer is linear, so it's determined by its output on each unit vector.
+	columns := matrix.Matrix{}
+	for k := 0; k < 128; k++ {
+		in := matrix.NewRow(128)
+		in.SetBit(k, true)
+		columns = append(columns, matrix.Row(out.decodeAll(eval(in))))
+	}
+
+	inverse, ok := columns.Transpose().Invert()
+	if !ok {
+		return out, errors.New("Layer isn't invertible!")
+	}
+	out.inverse = inverse
+
+	return out, nil
+}
+
+// decodeAll decodes every nibble of in.
+func (el *encodedLinear) decodeAll(in []byte) []byte {
+	out := make([]byte, 16)
+	for n := 0; n < 32; n++ {
+		setNibble(out, n, el.decode[n][nibble(in, n)])
+	}
+
+	return out
+}
+
+func (el *encodedLinear) invert(dst, src []byte) {
+	copy(dst, el.inverse.Mul(matrix.Row(el.decodeAll(src))))
+}
+
+// byteSum inverts a layer whose output is the sum of a function of each byte of its input, like the Epilogue. Each
+// function's image is a subspace, and every output is a sum of one vector from each subspace.
+type byteSum struct {
+	zero    []byte        // zero is the layer's output on zero.
+	coords  matrix.Matrix // coords maps an output, plus zero, to its coordinates in the subspaces' bases.
+	decoder [16][256]byte // decoder[i][c] is the i-th input byte, when the i-th coordinates are c.
+}
+
+func newByteSum(layer cipher.Block) (out byteSum, err error) {
+	eval := func(pos int, x byte) matrix.Row {
+		in, res := make([]byte, 16), make([]byte, 16)
+		in[pos] = x
+		layer.Encrypt(res, in)
+
+		for i := range res {
+			res[i] ^= out.zero[i]
+		}
+		return matrix.Row(res)
+	}
+	out.zero = make([]byte, 16)
+	layer.Encrypt(out.zero, make([]byte, 16))
+
+	basis := matrix.Matrix{}
+	for pos := 0; pos < 16; pos++ {
+		im := matrix.NewIncrementalMatrix(128)
+		for x := 1; x < 256 && im.Size() < 8; x++ {
+			if v := eval(pos, byte(x)); !im.IsIn(v) {
+				im.Add(v)
+				basis = append(basis, v)
+			}
+		}
+
+		if im.Size() != 8 {
+			return out, errors.New("Layer isn't a sum of bijections on each byte!")
+		}
+	}
+
+	coords, ok := basis.Transpose().Invert()
+	if !ok {
+		return out, errors.New("Layer isn't invertible!")
+	}
+	out.coords = coords
+
+	for pos := 0; pos < 16; pos++ {
+		for x := 0; x < 256; x++ {
+			out.decoder[pos][out.coords.Mul(eval(pos, byte(x)))[pos]] = byte(x)
+		}
+	}
+
+	return out, nil
+}
+
+func (bs *byteSum) invert(dst, src []byte) {
+	sum := make([]byte, 16)
+	for i := range sum {
+		sum[i] = src[i] ^ bs.zero[i]
+	}
+
+	c := bs.coords.Mul(matrix.Row(sum))
+	for pos := 0; pos < 16; pos++ {
+		dst[pos] = bs.decoder[pos][c[pos]]
+	}
+}
+
+// halfRound inverts half of a round on one column: four tables that expand each byte of the column into a word, and
+// the XOR tables that squash the words back into one, ((a ^ b) ^ c) ^ d. It meets in the middle: for every guess of the
+// last two bytes, it undoes the last two XORs and looks up what's left among the outputs of the first XOR.
+type halfRound struct {
+	words [4][256][4]byte
+	inv   [8][3][16][16]byte // inv[n][g][v][z] is the u that gate g of nibble n maps (u, v) to z with.
+
+	// first holds the output of the first XOR on every pair of first two bytes, in its high 32 bits, and the pair in its
+	// low 16. It's sorted.
+	first []uint64
+}
+
+func newHalfRound(tables []table.Word, xors [][3]table.Nibble) *halfRound {
+	hr := &halfRound{}
+
+	gates := [8][3][256]byte{}
+	for n := 0; n < 8; n++ {
+		for g := 0; g < 3; g++ {
+			for i := 0; i < 256; i++ {
+				gates[n][g][i] = xors[n][g].Get(byte(i))
+				hr.inv[n][g][i&0x0f][gates[n][g][i]] = byte(i >> 4)
+			}
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		for x := 0; x < 256; x++ {
+			hr.words[i][x] = tables[i].Get(byte(x))
+		}
+	}
+
+	hr.first = make([]uint64, 0, 65536)
+	for ab := 0; ab < 65536; ab++ {
+		a, b := hr.words[0][ab>>8], hr.words[1][ab&0xff]
+
+		sum := uint64(0)
+		for n := 0; n < 8; n++ {
+			sum = sum<<4 | uint64(gates[n][0][nibble(a[:], n)<<4|nibble(b[:], n)])
+		}
+
+		hr.first = append(hr.first, sum<<16|uint64(ab))
+	}
+	sort.Slice(hr.first, func(i, j int) bool { return hr.first[i] < hr.first[j] })
+
+	return hr
+}
+
+// invert finds the column that the half round maps to out.
+func (hr *halfRound) invert(out []byte) (in [4]byte, ok bool) {
+	for cd := 0; cd < 65536; cd++ {
+		c, d := hr.words[2][cd>>8], hr.words[3][cd&0xff]
+
+		sum := uint64(0)
+		for n := 0; n < 8; n++ {
+			m := hr.inv[n][2][nibble(d[:], n)][nibble(out, n)]
+			sum = sum<<4 | uint64(hr.inv[n][1][nibble(c[:], n)][m])
+		}
+
+		i := sort.Search(len(hr.first), func(i int) bool { return hr.first[i]>>16 >= sum })
+		if i < len(hr.first) && hr.first[i]>>16 == sum {
+			ab := hr.first[i] & 0xffff
+			return [4]byte{byte(ab >> 8), byte(ab), byte(cd >> 8), byte(cd)}, true
+		}
+	}
+
+	return
+}
+
+// inverted is an encryption construction that can also decrypt.
+type inverted struct {
+	*chow.Construction
+
+	prologue encodedLinear
+	rounds   [9][4][2]*halfRound // [round][column][half]
+	epilogue byteSum
+}
+
+// inverts returns true if inv undoes layer on a few random inputs.
+func inverts(layer cipher.Block, inv func(dst, src []byte)) bool {
+	r := rand.New(rand.NewSource(1))
+	in, out := make([]byte, 16), make([]byte, 16)
+
+	for i := 0; i < 16; i++ {
+		r.Read(in)
+		layer.Encrypt(out, in)
+		inv(out, out)
+
+		if !bytes.Equal(in, out) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Invert returns a cipher.Block that encrypts with an encryption construction and decrypts with its inverse. The
+// inverse is computed from the construction's tables alone, so it needs neither the key nor the external masks:
+// decryption is no harder than encryption. Setting up takes a few seconds, and decrypting each block a fraction of one.
+//
+// Affine external encodings are inverted along with the masks, but nonlinear ones aren't: the Prologue and Epilogue are
+// then no longer affine up to their nibble encodings, and Invert returns an error.
+func Invert(constr *chow.Construction) (cipher.Block, error) {
+	out := &inverted{Construction: constr}
+
+	var err error
+	if out.prologue, err = newEncodedLinear(constr.Prologue()); err != nil {
+		return nil, err
+	} else if out.epilogue, err = newByteSum(constr.Epilogue()); err != nil {
+		return nil, err
+	}
+
+	// A nonlinear external encoding can still fit the shape each inverse expects, so check them.
+	if !inverts(constr.Prologue(), out.prologue.invert) {
+		return nil, errors.New("Prologue isn't an encoded affine transformation!")
+	} else if !inverts(constr.Epilogue(), out.epilogue.invert) {
+		return nil, errors.New("Epilogue isn't a sum of bijections on each byte!")
+	}
+
+	for round := 0; round < 9; round++ {
+		for col := 0; col < 4; col++ {
+			pos := 4 * col
+
+			out.rounds[round][col] = [2]*halfRound{
+				newHalfRound(constr.TBoxTyiTable[round][pos:pos+4], constr.HighXORTable[round][2*pos:2*pos+8]),
+				newHalfRound(constr.MBInverseTable[round][pos:pos+4], constr.LowXORTable[round][2*pos:2*pos+8]),
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
+func (inv *inverted) Decrypt(dst, src []byte) {
+	aes := saes.Construction{}
+
+	state := make([]byte, 16)
+	inv.epilogue.invert(state, src[:16])
+	aes.UnShiftRows(state)
+
+	for round := 8; round >= 0; round-- {
+		for col := 0; col < 4; col++ {
+			for half := 1; half >= 0; half-- {
+				in, ok := inv.rounds[round][col][half].invert(state[4*col : 4*col+4])
+				if !ok {
+					panic("Decryption failed!")
+				}
+				copy(state[4*col:], in[:])
+			}
+		}
+
+		aes.UnShiftRows(state)
+	}
+
+	inv.prologue.invert(dst, state)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/OpenWhiteBox/AES/constructions/common"
 	"github.com/OpenWhiteBox/AES/constructions/saes"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/equivalence"
 )
 
 // sbox is a Byte encoding of AES's "standard" S-box.
@@ -104,25 +105,24 @@ func (sbl *sboxLayer) cleanLinear() (input, output encoding.ConcatenatedBlock) {
 
 // findConstant returns the constant error on the input and output of the middle S-box at position pos.
 func (sbl *sboxLayer) findConstant(pos int) (byte, byte) {
-	for b := 0; b < 256; b++ { // Try to guess the constant on the input.
-		correction := encoding.ByteAdditive(b)
-		vec := encoding.ComposedBytes{invert{}, correction, sbl[pos]}
-
-		// If the guess was correct, removing the constant and a field inversion from the input will result in an affine
-		// function (the linear error, multiplication by an element of GF(2^8), moves through inversion).
-
-		cand, ok := encoding.DecomposeByteAffine(vec)
-
-		if ok && encoding.EquivalentBytes(vec, cand) {
-			return byte(b), byte(cand.ByteAdditive)
-		}
+	// The S-box is affine equivalent to AES's. The self-equivalences of AES's S-box are linear on its input, so every
+	// equivalence has the same constant on its input: the constant error. The output's constant error is whatever the
+	// S-box maps it to.
+	eqs := equivalence.FindAffine(sbl[pos], sbox{}, 1)
+	if len(eqs) == 0 {
+		panic("Failed to find constant!")
 	}
 
-	panic("Failed to find constant!")
+	in := byte(eqs[0].A.ByteAdditive)
+	return in, sbl[pos].Encode(in)
 }
 
 // findLinear returns the linear error on the input and output of the mmiddle S-box at position pos (once the constant
 // error has been removed). The function is a simple brute force attack.
+//
+// equivalence.FindAffine isn't used here: the errors have to be multiplications, so that they move through MixColumns
+// and are the same across a column, and only one of the S-box's 2040 self-equivalences gives that. Finding it among all
+// of them costs more than searching the multiplications directly.
 func (sbl *sboxLayer) findLinear(pos int) (encoding.ByteMultiplication, encoding.ByteMultiplication) {
 	subBytes := encoding.NewByteLinear(matrix.Matrix{
 		matrix.Row{0xF1},
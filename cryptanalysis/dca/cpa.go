@@ -0,0 +1,250 @@
+// Package dca implements Differential Computation Analysis of white-box constructions: side-channel attacks on software
+// traces, like those collected by the trace package, that don't need to understand how a construction works.
+//
+// "Differential Computation Analysis: Hiding your White-Box Designs is Not Enough" by Joppe W. Bos, Charles Hubain,
+// Wil Michiels, and Philippe Teuwen, https://eprint.iacr.org/2015/753.pdf
+package dca
+
+import (
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/trace"
+)
+
+// Options configures a correlation power analysis.
+type Options struct {
+	// Models are the leakage models to try. A guess's score is its best correlation under any of them.
+	Models []Model
+
+	// Order is 1 to correlate with single samples, or 2 to correlate with the centered product of pairs of samples,
+	// which defeats first-order masking. Window is how many samples after each sample it's paired with.
+	Order, Window int
+
+	// Step is the number of traces between snapshots of the ranking. If it's 0, there is only a snapshot after the last
+	// trace.
+	Step int
+
+	// Workers is the number of key bytes attacked at once. If it's 0, it's the number of CPUs.
+	Workers int
+}
+
+// Ranking is the state of an attack after some number of traces.
+type Ranking struct {
+	Traces int              // The number of traces used.
+	Scores [16][256]float64 // The score of each guess for each key byte: its peak absolute correlation.
+	Key    [16]byte         // The best guess for each key byte.
+}
+
+// Rank returns the rank of guess for key byte pos: 0 if it's the best guess, 255 if it's the worst.
+func (r *Ranking) Rank(pos int, guess byte) (out int) {
+	for g := range r.Scores[pos] {
+		if r.Scores[pos][g] > r.Scores[pos][guess] {
+			out++
+		}
+	}
+
+	return
+}
+
+// Evolution is a series of snapshots of an attack's ranking, as it used more and more traces.
+type Evolution []Ranking
+
+// Ranks returns the rank of each byte of key in each snapshot. The attack on a byte has succeeded once its rank is 0.
+func (e Evolution) Ranks(key []byte) [][16]int {
+	out := make([][16]int, len(e))
+	for i := range e {
+		for pos := 0; pos < 16; pos++ {
+			out[i][pos] = e[i].Rank(pos, key[pos])
+		}
+	}
+
+	return out
+}
+
+// Final returns the ranking after all traces were used.
+func (e Evolution) Final() Ranking {
+	return e[len(e)-1]
+}
+
+// CPA runs a correlation power analysis against the output of the first round's S-boxes, one key byte at a time, and
+// returns how the ranking of the guesses for the first round key evolved as it used more traces. Traces must have
+// plaintexts without any external encoding.
+func CPA(traces []trace.Trace, opts Options) Evolution {
+	samples := make([][]float64, len(traces))
+	for i, t := range traces {
+		samples[i] = t.Samples
+	}
+	if opts.Order == 2 {
+		samples = combine(samples, opts.Window)
+	}
+
+	checkpoints := []int{}
+	for n := opts.Step; opts.Step > 0 && n < len(traces); n += opts.Step {
+		checkpoints = append(checkpoints, n)
+	}
+	checkpoints = append(checkpoints, len(traces))
+
+	out := make(Evolution, len(checkpoints))
+	for i, n := range checkpoints {
+		out[i].Traces = n
+	}
+
+	workers := opts.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+
+	positions := make(chan int, 16)
+	for pos := 0; pos < 16; pos++ {
+		positions <- pos
+	}
+	close(positions)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for pos := range positions {
+				attackByte(traces, samples, opts.Models, pos, checkpoints, out)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range out {
+		for pos := 0; pos < 16; pos++ {
+			for g := 1; g < 256; g++ {
+				if out[i].Scores[pos][g] > out[i].Scores[pos][out[i].Key[pos]] {
+					out[i].Key[pos] = byte(g)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// attackByte runs the attack on key byte pos, filling in its scores in each snapshot.
+func attackByte(traces []trace.Trace, samples [][]float64, models []Model, pos int, checkpoints []int, out Evolution) {
+	acc := newAccumulator(256*len(models), len(samples[0]))
+	hyps := make([]float64, 256*len(models))
+
+	snapshot := 0
+	for i, t := range traces {
+		for m, model := range models {
+			for g := 0; g < 256; g++ {
+				hyps[256*m+g] = model(saes.SBox[t.Plaintext[pos]^byte(g)])
+			}
+		}
+		acc.add(hyps, samples[i])
+
+		if i+1 == checkpoints[snapshot] {
+			for h, score := range acc.scores() {
+				if g := h % 256; score > out[snapshot].Scores[pos][g] {
+					out[snapshot].Scores[pos][g] = score
+				}
+			}
+			snapshot++
+		}
+	}
+}
+
+// combine replaces each trace with the centered products of each sample and the window samples after it.
+func combine(samples [][]float64, window int) [][]float64 {
+	width := len(samples[0])
+
+	mean := make([]float64, width)
+	for _, s := range samples {
+		for j, x := range s {
+			mean[j] += x / float64(len(samples))
+		}
+	}
+
+	out := make([][]float64, len(samples))
+	for i, s := range samples {
+		for j := 0; j < width; j++ {
+			for k := j + 1; k <= j+window && k < width; k++ {
+				out[i] = append(out[i], (s[j]-mean[j])*(s[k]-mean[k]))
+			}
+		}
+	}
+
+	return out
+}
+
+// accumulator keeps the sums needed to compute the correlation of each hypothesis with each sample.
+type accumulator struct {
+	n     float64
+	h, hh []float64 // [hypothesis]
+	x, xx []float64 // [sample]
+	hx    []float64 // [hypothesis*samples + sample]
+	width int
+}
+
+func newAccumulator(hyps, width int) *accumulator {
+	return &accumulator{
+		h: make([]float64, hyps), hh: make([]float64, hyps),
+		x: make([]float64, width), xx: make([]float64, width),
+		hx:    make([]float64, hyps*width),
+		width: width,
+	}
+}
+
+func (acc *accumulator) add(hyps, samples []float64) {
+	acc.n++
+
+	for j, x := range samples {
+		acc.x[j] += x
+		acc.xx[j] += x * x
+	}
+
+	for i, h := range hyps {
+		acc.h[i] += h
+		acc.hh[i] += h * h
+
+		if h == 0 {
+			continue
+		}
+
+		row := acc.hx[i*acc.width : (i+1)*acc.width]
+		for j, x := range samples {
+			row[j] += h * x
+		}
+	}
+}
+
+// scores returns, for each hypothesis, its largest absolute correlation with any sample.
+func (acc *accumulator) scores() []float64 {
+	n := acc.n
+	out := make([]float64, len(acc.h))
+
+	xdev := make([]float64, acc.width)
+	for j := range xdev {
+		xdev[j] = math.Sqrt(n*acc.xx[j] - acc.x[j]*acc.x[j])
+	}
+
+	for i := range out {
+		hdev := math.Sqrt(n*acc.hh[i] - acc.h[i]*acc.h[i])
+		if hdev == 0 {
+			continue
+		}
+
+		row := acc.hx[i*acc.width : (i+1)*acc.width]
+		for j := range row {
+			if xdev[j] == 0 {
+				continue
+			}
+
+			if corr := math.Abs(n*row[j]-acc.h[i]*acc.x[j]) / (hdev * xdev[j]); corr > out[i] {
+				out[i] = corr
+			}
+		}
+	}
+
+	return out
+}
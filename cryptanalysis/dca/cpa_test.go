@@ -0,0 +1,173 @@
+package dca
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+	"github.com/OpenWhiteBox/AES/constructions/xiao"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/trace"
+)
+
+var key = []byte{72, 101, 108, 108, 111, 32, 87, 111, 114, 108, 100, 33, 33, 33, 33, 33}
+
+// synthetic returns n traces of an implementation that leaks the output of each first-round S-box. If masked is set, each
+// output is XORed with a fresh random mask, and the mask is leaked right before it.
+func synthetic(n int, masked bool) []trace.Trace {
+	r := rand.New(rand.NewSource(1))
+	out := make([]trace.Trace, n)
+
+	for i := range out {
+		out[i].Plaintext = make([]byte, 16)
+		r.Read(out[i].Plaintext)
+
+		for pos := 0; pos < 16; pos++ {
+			x := saes.SBox[out[i].Plaintext[pos]^key[pos]]
+
+			if masked {
+				m := byte(r.Intn(256))
+				out[i].Samples = append(out[i].Samples, float64(m), float64(x^m))
+			} else {
+				out[i].Samples = append(out[i].Samples, float64(r.Intn(256)), float64(x))
+			}
+		}
+	}
+
+	return out
+}
+
+// window is a Tracer that counts the samples trace.Collect records up to the end of the first round.
+type window struct {
+	samples int
+}
+
+func (w *window) OnLookup(round, _ int, _ string, _, out []byte) {
+	if round <= 0 {
+		w.samples += len(out)
+	}
+}
+
+func (w *window) OnState(_ int, _ []byte) {}
+
+// collect returns n traces of a real construction, cut down to the samples of its Prologue and first round.
+func collect(constr trace.Traceable, n int) []trace.Trace {
+	w := &window{}
+	constr.Traced(w).Encrypt(make([]byte, 16), make([]byte, 16))
+
+	r := rand.New(rand.NewSource(1))
+	out := make([]trace.Trace, n)
+
+	for i := range out {
+		plaintext := make([]byte, 16)
+		r.Read(plaintext)
+
+		out[i] = trace.Collect(constr, plaintext)
+		out[i].Samples = out[i].Samples[:w.samples]
+	}
+
+	return out
+}
+
+// recovered returns the positions of the key bytes that have rank 0 in a ranking.
+func recovered(ranking Ranking) (out []int) {
+	for pos := 0; pos < 16; pos++ {
+		if ranking.Rank(pos, key[pos]) == 0 {
+			out = append(out, pos)
+		}
+	}
+
+	return
+}
+
+func TestFirstOrder(t *testing.T) {
+	for _, models := range [][]Model{{HammingWeight}, Bits()} {
+		evolution := CPA(synthetic(200, false), Options{Models: models, Order: 1, Step: 50})
+
+		if len(evolution) != 4 || evolution[3].Traces != 200 {
+			t.Fatalf("Wrong snapshots! %v", len(evolution))
+		}
+
+		cand := evolution.Final().Key
+		if !bytes.Equal(cand[:], key) {
+			t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, cand)
+		} else if ranks := evolution.Ranks(key); ranks[3] != [16]int{} {
+			t.Fatalf("Ranks of the real key aren't all zero! %v", ranks[3])
+		}
+	}
+}
+
+func TestSecondOrder(t *testing.T) {
+	traces := synthetic(3000, true)
+
+	// First-order CPA can't see through the masks.
+	first := CPA(traces, Options{Models: []Model{HammingWeight}, Order: 1}).Final().Key
+	if bytes.Equal(first[:], key) {
+		t.Fatalf("First-order CPA recovered a masked key!")
+	}
+
+	second := CPA(traces, Options{Models: []Model{HammingWeight}, Order: 2, Window: 1}).Final().Key
+	if !bytes.Equal(second[:], key) {
+		t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, second)
+	}
+}
+
+func TestFirstOrderReal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping CPA on real traces in short mode!")
+	}
+
+	opts := common.IndependentMasks{common.IdentityMask, common.RandomMask}
+
+	// The nibble encodings on Chow et al.'s tables are unbalanced for some key bytes, so single bits of their output
+	// correlate with the S-box's output.
+	chowConstr, _, _ := chow.GenerateEncryptionKeys(key, key, opts)
+	ranking := CPA(collect(&chowConstr, 500), Options{Models: Bits(), Order: 1}).Final()
+
+	if pos := recovered(ranking); len(pos) < 6 {
+		t.Fatalf("First-order CPA on chow recovered too few key bytes! %v", pos)
+	}
+
+	// Xiao et al.'s tables only have linear encodings, which spread each bit of the S-box's output over many bits.
+	xiaoConstr, _, _ := xiao.GenerateEncryptionKeys(key, key, opts)
+	ranking = CPA(collect(&xiaoConstr, 500), Options{Models: Bits(), Order: 1}).Final()
+
+	if pos := recovered(ranking); len(pos) != 0 {
+		t.Fatalf("First-order CPA on xiao recovered key bytes! %v", pos)
+	}
+}
+
+func TestSecondOrderReal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping CPA on real traces in short mode!")
+	}
+
+	constr, _, _ := chow.GenerateEncryptionKeys(key, key, common.IndependentMasks{common.IdentityMask, common.RandomMask})
+	traces := collect(&constr, 500)
+
+	first := CPA(traces, Options{Models: Bits(), Order: 1}).Final()
+	second := CPA(traces, Options{Models: Bits(), Order: 2, Window: 2}).Final()
+
+	// Products of neighboring nibbles recover key bytes whose nibble encodings hide them from first-order CPA.
+	found := []int{}
+	for _, pos := range recovered(second) {
+		if first.Rank(pos, key[pos]) > 0 {
+			found = append(found, pos)
+		}
+	}
+
+	if len(found) < 2 {
+		t.Fatalf("Second-order CPA on chow didn't recover new key bytes! %v, %v", recovered(first), recovered(second))
+	}
+}
+
+func BenchmarkCPA(b *testing.B) {
+	traces := synthetic(500, false)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		CPA(traces, Options{Models: Bits(), Order: 1})
+	}
+}
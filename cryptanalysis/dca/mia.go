@@ -0,0 +1,298 @@
+package dca
+
+import (
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/trace"
+)
+
+// Nibble returns a model that predicts that nibble i of the byte leaks, in any way: 0 is the low nibble and 1 the high
+// one. The whole byte would be useless, because a bijective S-box makes every key guess group the traces the same way,
+// but a nibble lets MIA tell the guesses apart without assuming anything about how it leaks. It fits nibble encodings
+// like Chow et al.'s.
+func Nibble(i uint) Model {
+	return func(x byte) float64 { return float64(x >> (4 * i) & 0x0f) }
+}
+
+// Nibbles returns a model for each nibble of the byte.
+func Nibbles() []Model {
+	return []Model{Nibble(0), Nibble(1)}
+}
+
+// cell is part of a sample's weight, put on one cell of a discretization of the samples' range.
+type cell struct {
+	index  int
+	weight float64
+}
+
+// Estimator estimates the distribution of a sample. Every sample's weight is spread over a fixed set of cells, and
+// mutual information is computed between the cells and the predicted leakage.
+type Estimator interface {
+	// spread returns the number of cells and how each sample is spread over them.
+	spread(samples []float64) (int, [][]cell)
+}
+
+// bounds returns the smallest and largest sample.
+func bounds(samples []float64) (lo, hi float64) {
+	lo, hi = samples[0], samples[0]
+	for _, x := range samples {
+		lo, hi = math.Min(lo, x), math.Max(hi, x)
+	}
+
+	return
+}
+
+// Histogram puts each sample in one of Bins bins of equal width.
+type Histogram struct {
+	Bins int
+}
+
+func (h Histogram) spread(samples []float64) (int, [][]cell) {
+	lo, hi := bounds(samples)
+	width := (hi - lo) / float64(h.Bins)
+
+	out := make([][]cell, len(samples))
+	for i, x := range samples {
+		bin := 0
+		if width > 0 {
+			bin = int((x - lo) / width)
+		}
+		if bin >= h.Bins {
+			bin = h.Bins - 1
+		}
+
+		out[i] = []cell{{bin, 1}}
+	}
+
+	return h.Bins, out
+}
+
+// Kernel spreads each sample over Points evenly spaced points with a Gaussian kernel. If Bandwidth is 0, it's chosen
+// with Silverman's rule of thumb.
+type Kernel struct {
+	Points    int
+	Bandwidth float64
+}
+
+func (k Kernel) spread(samples []float64) (int, [][]cell) {
+	lo, hi := bounds(samples)
+
+	bandwidth := k.Bandwidth
+	if bandwidth == 0 {
+		mean, variance := 0.0, 0.0
+		for _, x := range samples {
+			mean += x / float64(len(samples))
+		}
+		for _, x := range samples {
+			variance += (x - mean) * (x - mean) / float64(len(samples))
+		}
+
+		bandwidth = 1.06 * math.Sqrt(variance) * math.Pow(float64(len(samples)), -0.2)
+	}
+	if bandwidth == 0 {
+		bandwidth = 1
+	}
+
+	points := make([]float64, k.Points)
+	for i := range points {
+		points[i] = lo + (hi-lo)*float64(i)/float64(k.Points-1)
+	}
+
+	out := make([][]cell, len(samples))
+	for i, x := range samples {
+		total := 0.0
+		out[i] = make([]cell, k.Points)
+
+		for j, p := range points {
+			d := (x - p) / bandwidth
+			out[i][j] = cell{j, math.Exp(-d * d / 2)}
+			total += out[i][j].weight
+		}
+
+		for j := range out[i] {
+			out[i][j].weight /= total
+		}
+	}
+
+	return k.Points, out
+}
+
+// MIAOptions configures a mutual information analysis.
+type MIAOptions struct {
+	// Models are the leakage models to try. They only decide which S-box outputs are grouped together; their values
+	// don't matter. A guess's score is its best mutual information under any of them.
+	Models []Model
+
+	// Estimator estimates the distribution of each sample.
+	Estimator Estimator
+
+	// Step and Workers are the same as in Options.
+	Step, Workers int
+}
+
+// MIA runs a mutual information analysis against the output of the first round's S-boxes, one key byte at a time, and
+// returns how the ranking of the guesses for the first round key evolved as it used more traces. A guess's score is
+// its largest mutual information, in bits, with any sample. Traces must have plaintexts without any external encoding.
+func MIA(traces []trace.Trace, opts MIAOptions) Evolution {
+	checkpoints := []int{}
+	for n := opts.Step; opts.Step > 0 && n < len(traces); n += opts.Step {
+		checkpoints = append(checkpoints, n)
+	}
+	checkpoints = append(checkpoints, len(traces))
+
+	out := make(Evolution, len(checkpoints))
+	for i, n := range checkpoints {
+		out[i].Traces = n
+	}
+
+	// classes[m][x] is the group model m puts S-box output x in.
+	classes := make([][256]int, len(opts.Models))
+	for m, model := range opts.Models {
+		seen := make(map[float64]int)
+		for x := 0; x < 256; x++ {
+			h := model(byte(x))
+			if _, ok := seen[h]; !ok {
+				seen[h] = len(seen)
+			}
+			classes[m][x] = seen[h]
+		}
+	}
+
+	workers := opts.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+
+	positions := make(chan int, 16)
+	for pos := 0; pos < 16; pos++ {
+		positions <- pos
+	}
+	close(positions)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for pos := range positions {
+				for col := range traces[0].Samples {
+					column := make([]float64, len(traces))
+					for i, t := range traces {
+						column[i] = t.Samples[col]
+					}
+
+					miaColumn(traces, column, opts.Estimator, classes, pos, checkpoints, out)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range out {
+		for pos := 0; pos < 16; pos++ {
+			for g := 1; g < 256; g++ {
+				if out[i].Scores[pos][g] > out[i].Scores[pos][out[i].Key[pos]] {
+					out[i].Key[pos] = byte(g)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// miaColumn scores every guess for key byte pos against one column of samples, raising its score in each snapshot if
+// this column beats the others.
+func miaColumn(traces []trace.Trace, column []float64, est Estimator, classes [][256]int, pos int, checkpoints []int, out Evolution) {
+	cells, spread := est.spread(column)
+
+	// byInput[v][c] is the weight on cell c of the traces whose plaintext byte is v, and touched[v] lists the cells
+	// where it isn't zero.
+	byInput, touched := make([][]float64, 256), make([][]int, 256)
+	for v := range byInput {
+		byInput[v] = make([]float64, cells)
+	}
+
+	// groups[m] is the number of groups model m has.
+	groups := make([]int, len(classes))
+	for m := range classes {
+		for _, k := range classes[m] {
+			if k+1 > groups[m] {
+				groups[m] = k + 1
+			}
+		}
+	}
+
+	snapshot := 0
+	for i, t := range traces {
+		v := t.Plaintext[pos]
+		for _, c := range spread[i] {
+			if byInput[v][c.index] == 0 {
+				touched[v] = append(touched[v], c.index)
+			}
+			byInput[v][c.index] += c.weight
+		}
+
+		if i+1 != checkpoints[snapshot] {
+			continue
+		}
+
+		marginal := make([]float64, cells)
+		for v := range byInput {
+			for _, c := range touched[v] {
+				marginal[c] += byInput[v][c]
+			}
+		}
+
+		for m := range classes {
+			joint := make([][]float64, groups[m])
+			for k := range joint {
+				joint[k] = make([]float64, cells)
+			}
+
+			for g := 0; g < 256; g++ {
+				for k := range joint {
+					for c := range joint[k] {
+						joint[k][c] = 0
+					}
+				}
+
+				for v := range byInput {
+					k := classes[m][saes.SBox[byte(v)^byte(g)]]
+					for _, c := range touched[v] {
+						joint[k][c] += byInput[v][c]
+					}
+				}
+
+				if mi := mutualInformation(joint, marginal, float64(i+1)); mi > out[snapshot].Scores[pos][g] {
+					out[snapshot].Scores[pos][g] = mi
+				}
+			}
+		}
+
+		snapshot++
+	}
+}
+
+// mutualInformation computes the mutual information, in bits, between the groups and cells of a joint distribution of
+// total weight n, given the distribution's marginal over cells.
+func mutualInformation(joint [][]float64, marginal []float64, n float64) (out float64) {
+	for _, row := range joint {
+		group := 0.0
+		for _, w := range row {
+			group += w
+		}
+
+		for c, w := range row {
+			if w > 0 {
+				out += w / n * math.Log2(w*n/(group*marginal[c]))
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,136 @@
+package dca
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/full"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+	"github.com/OpenWhiteBox/AES/constructions/toy"
+	"github.com/OpenWhiteBox/AES/constructions/xiao"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/trace"
+)
+
+var seed = []byte{38, 41, 142, 156, 29, 181, 23, 194, 21, 250, 223, 183, 210, 168, 214, 145}
+
+// encoded returns n traces of an implementation that leaks the high nibble of each first-round S-box's output under a
+// random encoding, which a linear model doesn't fit.
+func encoded(n int) []trace.Trace {
+	r := rand.New(rand.NewSource(1))
+
+	encodings := [16][]int{}
+	for i := range encodings {
+		encodings[i] = r.Perm(16)
+	}
+
+	out := make([]trace.Trace, n)
+	for i := range out {
+		out[i].Plaintext = make([]byte, 16)
+		r.Read(out[i].Plaintext)
+
+		for pos := 0; pos < 16; pos++ {
+			x := saes.SBox[out[i].Plaintext[pos]^key[pos]]
+			out[i].Samples = append(out[i].Samples, float64(encodings[pos][x>>4]))
+		}
+	}
+
+	return out
+}
+
+func TestMIA(t *testing.T) {
+	traces := encoded(1000)
+
+	for _, opts := range []MIAOptions{
+		{Models: Bits(), Estimator: Histogram{16}},
+		{Models: Bits(), Estimator: Kernel{Points: 16}},
+		{Models: Nibbles(), Estimator: Histogram{16}},
+	} {
+		opts.Step = 500
+		evolution := MIA(traces, opts)
+
+		if cand := evolution.Final().Key; !bytes.Equal(cand[:], key) {
+			t.Fatalf("Recovered wrong key with %#v!\nreal=%x\ncand=%x", opts.Estimator, key, cand)
+		} else if len(evolution) != 2 {
+			t.Fatalf("Wrong snapshots! %v != 2", len(evolution))
+		}
+	}
+}
+
+func TestMIAChow(t *testing.T) {
+	constr, _, _ := chow.GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.IdentityMask, common.RandomMask})
+	r := rand.New(rand.NewSource(1))
+
+	// Keep the output of the first round's T-Box/Tyi tables for the first column, which come right after the 16 slices
+	// and 480 XOR tables of the input mask.
+	traces := make([]trace.Trace, 1000)
+	for i := range traces {
+		plaintext := make([]byte, 16)
+		r.Read(plaintext)
+
+		traces[i] = trace.Collect(&constr, plaintext)
+		traces[i].Samples = nibbles(traces[i].Samples[16*16+480 : 16*16+480+16])
+	}
+
+	for _, models := range [][]Model{Bits(), Nibbles()} {
+		ranking := MIA(traces, MIAOptions{Models: models, Estimator: Histogram{16}}).Final()
+
+		// The first column of the first round depends on key bytes 0, 5, 10, and 15.
+		for _, pos := range []int{0, 5, 10, 15} {
+			if rank := ranking.Rank(pos, key[pos]); rank != 0 {
+				t.Fatalf("Key byte %v has rank %v, not 0!", pos, rank)
+			}
+		}
+	}
+}
+
+func TestMIAResistant(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the MIA resistance test in short mode!")
+	}
+
+	xiaoConstr, _, _ := xiao.GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.IdentityMask, common.RandomMask})
+	toyConstr, _, _ := toy.GenerateKeys(key, seed)
+	fullConstr, _, _ := full.GenerateKeys(key, seed)
+
+	// Each construction leaks the first round within its first samples, but hides which key byte they depend on: Xiao-Lai
+	// encodes pairs of S-boxes together, and toy and full have an affine mask over the whole input.
+	for _, target := range []struct {
+		name   string
+		constr trace.Traceable
+		window int
+	}{
+		{"xiao", &xiaoConstr, 32},
+		{"toy", &toyConstr, 16},
+		{"full", &fullConstr, 120},
+	} {
+		r := rand.New(rand.NewSource(1))
+
+		traces := make([]trace.Trace, 256)
+		for i := range traces {
+			plaintext := make([]byte, 16)
+			r.Read(plaintext)
+
+			traces[i] = trace.Collect(target.constr, plaintext)
+			traces[i].Samples = traces[i].Samples[:target.window]
+		}
+
+		cand := MIA(traces, MIAOptions{Models: Bits(), Estimator: Histogram{16}}).Final().Key
+		if bytes.Equal(cand[:], key) {
+			t.Fatalf("Recovered the key from %v!", target.name)
+		}
+	}
+}
+
+// nibbles splits each byte-valued sample into its two nibbles. A table's output byte is a bijection of its input, so
+// the nibbles are what leak about part of it.
+func nibbles(samples []float64) []float64 {
+	out := make([]float64, 0, 2*len(samples))
+	for _, x := range samples {
+		out = append(out, float64(byte(x)>>4), float64(byte(x)&0xf))
+	}
+
+	return out
+}
@@ -0,0 +1,30 @@
+package dca
+
+// Model predicts the leakage of an intermediate value of AES.
+type Model func(x byte) float64
+
+// HammingWeight predicts that the whole byte leaks through its Hamming weight.
+func HammingWeight(x byte) float64 {
+	out := 0
+	for ; x > 0; x &= x - 1 {
+		out++
+	}
+
+	return float64(out)
+}
+
+// Bit returns a model that predicts that bit i of the byte leaks by itself.
+func Bit(i uint) Model {
+	return func(x byte) float64 { return float64(x >> i & 1) }
+}
+
+// Bits returns a model for each bit of the byte. Attacking with all of them is the usual DCA on software traces, where
+// single bits of a table's output are often correlated with the encoded values the table stores.
+func Bits() []Model {
+	out := make([]Model, 8)
+	for i := range out {
+		out[i] = Bit(uint(i))
+	}
+
+	return out
+}
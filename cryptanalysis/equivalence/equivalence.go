@@ -0,0 +1,97 @@
+// Package equivalence extends the linear equivalence algorithm in github.com/OpenWhiteBox/primitives/equivalence to
+// Biryukov et al.'s affine one, which finds the pairs of affine transformations A and B such that f(A(x)) = B(g(x)) for
+// two 8-bit permutations f and g. The self-equivalences of a permutation are its equivalences with itself.
+//
+// The affine algorithm guesses A's constant, which fixes B's, and runs the linear algorithm on the guesses that a
+// linear invariant doesn't rule out.
+//
+// https://www.iacr.org/archive/eurocrypt2003/26560033/26560033.pdf
+package equivalence
+
+import (
+	"math"
+
+	"github.com/OpenWhiteBox/primitives/encoding"
+	pequivalence "github.com/OpenWhiteBox/primitives/equivalence"
+)
+
+// Equivalence is a pair of affine transformations, A and B, such that f(A(x)) = B(g(x)) for all x.
+type Equivalence struct {
+	A, B encoding.ByteAffine
+}
+
+// permutation is the table of an 8-bit permutation and its inverse. It's a Byte encoding, so that the linear algorithm
+// doesn't evaluate the permutation it's built from over and over.
+type permutation struct {
+	forwards, backwards [256]byte
+}
+
+func (p *permutation) Encode(in byte) byte { return p.forwards[in] }
+func (p *permutation) Decode(in byte) byte { return p.backwards[in] }
+
+// shifted returns the permutation x -> f(x ^ a) ^ b.
+func shifted(f encoding.Byte, a, b byte) *permutation {
+	out := &permutation{}
+	for x := 0; x < 256; x++ {
+		y := f.Encode(byte(x)^a) ^ b
+		out.forwards[x], out.backwards[y] = y, byte(x)
+	}
+
+	return out
+}
+
+// additivity counts the pairs (x, y) where f(x ^ y) = f(x) ^ f(y). It's the same for linearly equivalent permutations
+// that fix zero.
+func additivity(f *permutation) (out int) {
+	for x := 0; x < 256; x++ {
+		for y := x; y < 256; y++ {
+			if f.forwards[x^y] == f.forwards[x]^f.forwards[y] {
+				out++
+			}
+		}
+	}
+
+	return
+}
+
+// FindAffine returns the affine equivalences between f and g, at most max of them, or all of them if max is negative.
+func FindAffine(f, g encoding.Byte, max int) (out []Equivalence) {
+	// Tabulate f, so that it's only evaluated once however many guesses are made.
+	f = shifted(f, 0x00, 0x00)
+
+	// Move g's constant off of its output, so that it fixes zero.
+	g0 := g.Encode(0x00)
+	gp := shifted(g, 0x00, g0)
+	target := additivity(gp)
+
+	// Guess A's constant, a. Then f(x ^ a) ^ f(a) fixes zero, and is linearly equivalent to gp iff the guess is right.
+	for a := 0; a < 256 && (max < 0 || len(out) < max); a++ {
+		fa := f.Encode(byte(a))
+		fp := shifted(f, byte(a), fa)
+
+		if additivity(fp) != target {
+			continue
+		}
+
+		left := math.MaxInt32
+		if max >= 0 {
+			left = max - len(out)
+		}
+
+		for _, eq := range pequivalence.FindLinear(fp, gp, left) {
+			// f(A(x) ^ a) ^ f(a) = B(g(x) ^ g(0)), so the constant on B's output is f(a) ^ B(g(0)).
+			out = append(out, Equivalence{
+				A: encoding.ByteAffine{ByteLinear: eq.A, ByteAdditive: encoding.ByteAdditive(a)},
+				B: encoding.ByteAffine{ByteLinear: eq.B, ByteAdditive: encoding.ByteAdditive(fa ^ eq.B.Encode(g0))},
+			})
+		}
+	}
+
+	return
+}
+
+// SelfEquivalences returns the affine self-equivalences of f, at most max of them, or all of them if max is negative.
+// AES's S-box has 2040 of them.
+func SelfEquivalences(f encoding.Byte, max int) []Equivalence {
+	return FindAffine(f, f, max)
+}
@@ -0,0 +1,62 @@
+package equivalence
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/OpenWhiteBox/primitives/encoding"
+	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// sbox is a Byte encoding of AES's "standard" S-box. It uses the tables, because checking every self-equivalence
+// evaluates it often.
+type sbox struct{}
+
+func (sbox sbox) Encode(in byte) byte { return saes.SBox[in] }
+func (sbox sbox) Decode(in byte) byte { return saes.InvSBox[in] }
+
+// randomAffine returns a random affine transformation.
+func randomAffine() encoding.ByteAffine {
+	constant := make([]byte, 1)
+	rand.Read(constant)
+
+	return encoding.ByteAffine{
+		ByteLinear:   encoding.NewByteLinear(matrix.GenerateRandom(rand.Reader, 8)),
+		ByteAdditive: encoding.ByteAdditive(constant[0]),
+	}
+}
+
+// isEquivalence returns true if f(A(x)) = B(g(x)) for all x.
+func isEquivalence(f, g encoding.Byte, eq Equivalence) bool {
+	return encoding.EquivalentBytes(
+		encoding.ComposedBytes{eq.A, f},
+		encoding.ComposedBytes{g, eq.B},
+	)
+}
+
+func TestFindAffine(t *testing.T) {
+	A, B := randomAffine(), randomAffine()
+	f, g := sbox{}, encoding.ComposedBytes{A, sbox{}, encoding.InverseByte{B}}
+
+	eqs := FindAffine(f, g, 1)
+	if len(eqs) != 1 {
+		t.Fatalf("Found %v affine equivalences, not 1!", len(eqs))
+	} else if !isEquivalence(f, g, eqs[0]) {
+		t.Fatal("Found the wrong affine equivalence!")
+	}
+}
+
+func TestSelfEquivalences(t *testing.T) {
+	eqs := SelfEquivalences(sbox{}, -1)
+	if len(eqs) != 2040 {
+		t.Fatalf("Found %v self-equivalences of the S-box, not 2040!", len(eqs))
+	}
+
+	for i, eq := range eqs {
+		if !isEquivalence(sbox{}, sbox{}, eq) {
+			t.Fatalf("Self-equivalence %v is wrong!", i)
+		}
+	}
+}
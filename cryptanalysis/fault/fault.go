@@ -0,0 +1,99 @@
+// Package fault runs fault-injection campaigns against white-box constructions. A campaign evaluates a construction on
+// a set of inputs with and without each of a set of faults and records the outputs, for use by fault analyses or as a
+// regression test of a construction's fault resistance.
+package fault
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// Fault is a single fault, injected into either the state at the end of a round or the output of a table lookup.
+//
+// If Kind is empty, the fault is on byte Position of the state at the end of round Round. Otherwise, it's on byte Offset
+// of the output of a lookup in a table of the given kind: the Occurrence-th lookup (counting from zero) at the given
+// round and position. The byte is overwritten with Value if Overwrite is set, and XORed with Value otherwise.
+type Fault struct {
+	Round, Position int
+	Kind            string `json:",omitempty"`
+	Occurrence      int    `json:",omitempty"`
+	Offset          int    `json:",omitempty"`
+
+	Overwrite bool `json:",omitempty"`
+	Value     byte
+
+	seen int
+}
+
+// Flip returns a fault that XORs mask into byte position of the state at the end of the given round.
+func Flip(round, position int, mask byte) Fault {
+	return Fault{Round: round, Position: position, Value: mask}
+}
+
+// Set returns a fault that overwrites byte position of the state at the end of the given round with value.
+func Set(round, position int, value byte) Fault {
+	return Fault{Round: round, Position: position, Overwrite: true, Value: value}
+}
+
+func (f *Fault) apply(b *byte) {
+	if f.Overwrite {
+		*b = f.Value
+	} else {
+		*b ^= f.Value
+	}
+}
+
+// InjectLookup applies f to out if it's the lookup f targets.
+func (f *Fault) InjectLookup(round, position int, kind string, out []byte) {
+	if f.Kind == "" || kind != f.Kind || round != f.Round || position != f.Position {
+		return
+	}
+
+	if f.seen == f.Occurrence && f.Offset < len(out) {
+		f.apply(&out[f.Offset])
+	}
+	f.seen++
+}
+
+// InjectState applies f to state if it's the state f targets.
+func (f *Fault) InjectState(round int, state []byte) {
+	if f.Kind == "" && round == f.Round && f.Position < len(state) {
+		f.apply(&state[f.Position])
+	}
+}
+
+// Faultable is a construction that can be evaluated with faults. The chow, xiao, toy, and full constructions are all
+// Faultable.
+type Faultable interface {
+	cipher.Block
+	Faulted(f common.FaultInjector) cipher.Block
+}
+
+// Result is the outcome of one fault on one input.
+type Result struct {
+	Fault                  Fault
+	Input, Correct, Faulty []byte
+}
+
+// Campaign encrypts every input with constr, once without faults and once with each fault, and returns the results in
+// order by input, then by fault.
+func Campaign(constr Faultable, faults []Fault, inputs [][]byte) []Result {
+	out := make([]Result, 0, len(faults)*len(inputs))
+
+	for _, input := range inputs {
+		correct := make([]byte, constr.BlockSize())
+		constr.Encrypt(correct, input)
+
+		for _, fault := range faults {
+			injector := fault
+
+			faulty := make([]byte, constr.BlockSize())
+			constr.Faulted(&injector).Encrypt(faulty, input)
+
+			out = append(out, Result{fault, append([]byte{}, input...), correct, faulty})
+		}
+	}
+
+	return out
+}
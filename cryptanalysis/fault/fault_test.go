@@ -0,0 +1,76 @@
+package fault
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/toy"
+)
+
+var (
+	key   = []byte{72, 101, 108, 108, 111, 32, 87, 111, 114, 108, 100, 33, 33, 33, 33, 33}
+	seed  = []byte{38, 41, 142, 156, 29, 181, 23, 194, 21, 250, 223, 183, 210, 168, 214, 145}
+	input = []byte{99, 83, 224, 140, 9, 96, 225, 4, 205, 112, 183, 81, 186, 202, 208, 231}
+)
+
+// differences counts the bytes where a and b differ.
+func differences(a, b []byte) (out int) {
+	for i := range a {
+		if a[i] != b[i] {
+			out++
+		}
+	}
+
+	return
+}
+
+func TestStateFault(t *testing.T) {
+	constr, _, _ := toy.GenerateKeys(key, seed)
+
+	res := Campaign(&constr, []Fault{Flip(4, 7, 0x01), Set(4, 7, 0x00)}, [][]byte{input})
+	if len(res) != 2 {
+		t.Fatalf("Campaign returned the wrong number of results! %v != 2", len(res))
+	}
+
+	real := make([]byte, 16)
+	constr.Encrypt(real, input)
+
+	if !bytes.Equal(res[0].Correct, real) {
+		t.Fatalf("Correct output is wrong! %x != %x", res[0].Correct, real)
+	} else if bytes.Equal(res[0].Faulty, real) {
+		t.Fatalf("Flipping a bit didn't change the output!")
+	}
+}
+
+func TestLookupFault(t *testing.T) {
+	constr, _, _ := chow.GenerateEncryptionKeys(key, seed, common.SameMasks(common.IdentityMask))
+
+	// A fault on one T-Box/Tyi table in the ninth round changes one column before the last ShiftRows.
+	res := Campaign(&constr, []Fault{{Round: 8, Position: 5, Kind: "TBoxTyi", Offset: 2, Value: 0x10}}, [][]byte{input})
+
+	if n := differences(res[0].Correct, res[0].Faulty); n != 4 {
+		t.Fatalf("Fault changed the wrong number of bytes! %v != 4", n)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	constr, _, _ := toy.GenerateKeys(key, seed)
+	res1 := Campaign(&constr, []Fault{Flip(0, 0, 0xff), Set(9, 15, 0x42)}, [][]byte{input, key})
+
+	buff := &bytes.Buffer{}
+	if err := Write(buff, res1); err != nil {
+		t.Fatal(err)
+	}
+
+	res2, err := Read(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res1, res2) {
+		t.Fatalf("Parsed results disagree with the originals!\n%v\n%v", res1, res2)
+	}
+}
@@ -0,0 +1,42 @@
+package fault
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Write writes results to w, one JSON object per line. Byte strings are base64-encoded.
+func Write(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+
+	for _, res := range results {
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read parses results written by Write.
+func Read(r io.Reader) ([]Result, error) {
+	out := []Result{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		res := Result{}
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			return nil, errors.New("Parsing the fault results failed!")
+		}
+
+		out = append(out, res)
+	}
+
+	return out, scanner.Err()
+}
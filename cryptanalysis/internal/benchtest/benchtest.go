@@ -0,0 +1,23 @@
+// Package benchtest has helpers for benchmarking the attacks.
+package benchtest
+
+import (
+	"runtime"
+	"testing"
+)
+
+// Procs benchmarks f with one processor, then with every processor, to show the speedup from parallelism.
+func Procs(b *testing.B, f func()) {
+	for _, procs := range []struct {
+		name string
+		n    int
+	}{{"Serial", 1}, {"Parallel", runtime.NumCPU()}} {
+		b.Run(procs.name, func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs.n))
+
+			for i := 0; i < b.N; i++ {
+				f()
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package keyschedule implements AES' key schedule for every key size, and its inversion: recovering the master key
+// from the round keys of any round. An attack that learns the round keys of one round can use it to target whichever
+// round is weakest.
+//
+// AES-128's master key is determined by any one round key. AES-192's and AES-256's are longer than a round key, so they
+// need two consecutive round keys.
+package keyschedule
+
+import (
+	"errors"
+
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+// Powers of x mod M(x).
+var powx = [16]byte{0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x1b, 0x36, 0x6c, 0xd8, 0xab, 0x4d, 0x9a, 0x2f}
+
+type word [4]byte
+
+func (w word) xor(v word) (out word) {
+	for i := range out {
+		out[i] = w[i] ^ v[i]
+	}
+
+	return
+}
+
+// Rounds returns the number of rounds of AES with a key of the given size, in bytes, or 0 if the size isn't 16, 24, or
+// 32.
+func Rounds(size int) int {
+	switch size {
+	case 16, 24, 32:
+		return size/4 + 6
+	default:
+		return 0
+	}
+}
+
+// g is the function of the previous word that the i-th word of the key schedule is XORed with, on top of the word a
+// key-length before it, for keys of nk words.
+func g(i, nk int, prev word) word {
+	constr := saes.Construction{}
+
+	switch {
+	case i%nk == 0:
+		out := word{}
+		for j := range out {
+			out[j] = constr.SubByte(prev[(j+1)%4])
+		}
+		out[0] ^= powx[i/nk-1]
+
+		return out
+	case nk > 6 && i%nk == 4:
+		out := word{}
+		for j := range out {
+			out[j] = constr.SubByte(prev[j])
+		}
+
+		return out
+	default:
+		return prev
+	}
+}
+
+// Expand returns the round keys derived from a 16-, 24-, or 32-byte master key, one for each round and one more. It
+// panics if the key's size is wrong.
+func Expand(key []byte) [][]byte {
+	nk, rounds := len(key)/4, Rounds(len(key))
+	if rounds == 0 {
+		panic("AES keys are 16, 24, or 32 bytes long")
+	}
+
+	words := make([]word, 4*(rounds+1))
+	for i := 0; i < nk; i++ {
+		copy(words[i][:], key[4*i:])
+	}
+	for i := nk; i < len(words); i++ {
+		words[i] = words[i-nk].xor(g(i, nk, words[i-1]))
+	}
+
+	out := make([][]byte, rounds+1)
+	for r := range out {
+		out[r] = make([]byte, 0, 16)
+		for _, w := range words[4*r : 4*r+4] {
+			out[r] = append(out[r], w[:]...)
+		}
+	}
+
+	return out
+}
+
+// RecoverKey returns the size-byte master key whose key schedule has the given round keys, starting from round key
+// first. AES-128 needs one round key and AES-192 and AES-256 need two. Any more are checked against the key schedule.
+func RecoverKey(size, first int, roundKeys ...[]byte) ([]byte, error) {
+	nk, rounds := size/4, Rounds(size)
+	if rounds == 0 {
+		return nil, errors.New("AES keys are 16, 24, or 32 bytes long!")
+	} else if first < 0 || first+len(roundKeys) > rounds+1 {
+		return nil, errors.New("Round keys are out of range!")
+	} else if 4*len(roundKeys) < nk {
+		return nil, errors.New("Not enough round keys to determine the master key!")
+	}
+
+	// Put the round keys' words where they are in the key schedule, and invert it from the first nk of them back to the
+	// start.
+	start, known := 4*first, []word{}
+	for _, roundKey := range roundKeys {
+		if len(roundKey) != 16 {
+			return nil, errors.New("Round keys are 16 bytes long!")
+		}
+		for i := 0; i < 4; i++ {
+			w := word{}
+			copy(w[:], roundKey[4*i:])
+			known = append(known, w)
+		}
+	}
+
+	window := append([]word{}, known[:nk]...)
+	for s := start; s > 0; s-- {
+		// The word before the window is the last word of the window XORed with g of the one before it.
+		prev := window[nk-1].xor(g(s-1+nk, nk, window[nk-2]))
+		window = append([]word{prev}, window[:nk-1]...)
+	}
+
+	key := make([]byte, 0, size)
+	for _, w := range window {
+		key = append(key, w[:]...)
+	}
+
+	// Check the rest of the round keys.
+	expanded := Expand(key)
+	for i, roundKey := range roundKeys {
+		for j := range roundKey {
+			if expanded[first+i][j] != roundKey[j] {
+				return nil, errors.New("Round keys are inconsistent with the key schedule!")
+			}
+		}
+	}
+
+	return key, nil
+}
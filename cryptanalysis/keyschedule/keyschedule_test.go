@@ -0,0 +1,83 @@
+package keyschedule
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+)
+
+func TestExpand(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr := saes.Construction{key}
+	real, cand := constr.StretchedKey(), Expand(key)
+
+	if len(cand) != len(real) {
+		t.Fatalf("Wrong number of round keys! %v != %v", len(cand), len(real))
+	}
+	for r := range real {
+		if !bytes.Equal(real[r], cand[r]) {
+			t.Fatalf("Real disagrees with result in round %v! %x != %x", r, real[r], cand[r])
+		}
+	}
+}
+
+// TestExpandVectors checks the last round key of each key size against FIPS-197, Appendix A.
+func TestExpandVectors(t *testing.T) {
+	vectors := []struct{ key, last string }{
+		{"2b7e151628aed2a6abf7158809cf4f3c", "d014f9a8c9ee2589e13f0cc8b6630ca6"},
+		{"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", "e98ba06f448c773c8ecc720401002202"},
+		{"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", "fe4890d1e6188d0b046df344706c631e"},
+	}
+
+	for _, v := range vectors {
+		key, _ := hex.DecodeString(v.key)
+		last, _ := hex.DecodeString(v.last)
+
+		roundKeys := Expand(key)
+		if cand := roundKeys[len(roundKeys)-1]; !bytes.Equal(cand, last) {
+			t.Fatalf("Real disagrees with result for %v-byte key! %x != %x", len(key), last, cand)
+		}
+	}
+}
+
+func TestRecoverKey(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		key := make([]byte, size)
+		rand.Read(key)
+
+		roundKeys := Expand(key)
+		needed := 1
+		if size > 16 {
+			needed = 2
+		}
+
+		for first := 0; first+needed <= len(roundKeys); first++ {
+			cand, err := RecoverKey(size, first, roundKeys[first:first+needed]...)
+			if err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(cand, key) {
+				t.Fatalf("Recovered wrong %v-byte key from round %v!\nreal=%x\ncand=%x", size, first, key, cand)
+			}
+		}
+	}
+}
+
+func TestRecoverKeyErrors(t *testing.T) {
+	key := make([]byte, 24)
+	rand.Read(key)
+	roundKeys := Expand(key)
+
+	// Two round keys of AES-192 have two words more than the master key, so they can be checked.
+	if _, err := RecoverKey(24, 3, roundKeys[3]); err == nil {
+		t.Fatal("RecoverKey accepted too few round keys!")
+	} else if _, err := RecoverKey(24, 3, roundKeys[3], roundKeys[5]); err == nil {
+		t.Fatal("RecoverKey accepted round keys that aren't consecutive!")
+	} else if _, err := RecoverKey(24, 12, roundKeys[11], roundKeys[12]); err == nil {
+		t.Fatal("RecoverKey accepted round keys out of range!")
+	}
+}
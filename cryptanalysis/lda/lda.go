@@ -0,0 +1,176 @@
+// Package lda implements Linear Decoding Analysis, an algebraic variant of DCA. It records the outputs of the lookups
+// in a construction's first round and, for each key byte, looks for a linear combination of their bits that equals a
+// bit of the S-box's output under some guess of the key byte. Encodings that are linear, or nibble-wise and expanded
+// into all products of a nibble's bits, can't hide the S-box's output from it.
+//
+// "How to Reveal the Secrets of an Obscure White-Box Implementation" by Louis Goubin, Pascal Paillier, Matthieu Rivain,
+// and Junwei Wang, https://eprint.iacr.org/2018/098.pdf
+package lda
+
+import (
+	"errors"
+	"io"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/saes"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/trace"
+)
+
+// Target says which lookups in a construction hide the first round's S-box outputs.
+type Target struct {
+	Round int
+	Kind  string
+
+	// Position returns the position of the lookups that depend on the given byte of the key.
+	Position func(pos int) int
+
+	// Nibbles expands each nibble of the lookups' output into the 15 non-empty products of its bits. This makes any
+	// nibble encoding linear, at the cost of more traces.
+	Nibbles bool
+}
+
+var (
+	// Chow targets the first round's T-Box/Tyi tables, whose output is linear in the S-box's output up to nibble
+	// encodings.
+	Chow = Target{Round: 0, Kind: "TBoxTyi", Position: common.ShiftRows, Nibbles: true}
+
+	// Xiao targets the first round's TBoxMixCol tables, whose output is linear in the S-box's output.
+	Xiao = Target{Round: 0, Kind: "TBoxMixCol", Position: func(pos int) int { return common.ShiftRows(pos) &^ 1 }}
+)
+
+// recorder is a Tracer that records the output of the lookups a target selects, by position.
+type recorder struct {
+	target  Target
+	outputs map[int][]byte
+}
+
+func (r *recorder) OnLookup(round, position int, kind string, _, out []byte) {
+	if round == r.target.Round && kind == r.target.Kind {
+		r.outputs[position] = append(r.outputs[position], out...)
+	}
+}
+
+func (r *recorder) OnState(_ int, _ []byte) {}
+
+// features expands the recorded output of some lookups into the bits the attack takes linear combinations of. The
+// first is always 1, so that combinations can be affine.
+func (t Target) features(out []byte) []bool {
+	res := []bool{true}
+
+	for _, b := range out {
+		if !t.Nibbles {
+			for i := uint(0); i < 8; i++ {
+				res = append(res, b>>i&1 == 1)
+			}
+			continue
+		}
+
+		for _, nibble := range []byte{b >> 4, b & 0x0f} {
+			for monomial := byte(1); monomial < 16; monomial++ {
+				res = append(res, nibble&monomial == monomial)
+			}
+		}
+	}
+
+	return res
+}
+
+// RecoverKey returns the AES key used to generate the given white-box construction, which must have no input mask.
+// It encrypts plaintexts read from rand with constr, a few more than the number of features the target has, so the
+// attack is reproducible when rand is. crypto/rand.Reader is a good choice otherwise.
+//
+// External encodings on the input break the attack. With a nonlinear one, no guess of a key byte predicts the lookups
+// and an error is returned. An affine one shifts every key byte by its constant, which can't be told apart from the key,
+// so the key that's returned is off by it.
+func RecoverKey(constr trace.Traceable, target Target, rand io.Reader) ([]byte, error) {
+	// Find out how many features each key byte has from one encryption, then collect enough for the rest.
+	plaintexts, outputs := [][]byte{}, []map[int][]byte{}
+	encrypt := func() error {
+		plaintext := make([]byte, 16)
+		if _, err := io.ReadFull(rand, plaintext); err != nil {
+			return err
+		}
+
+		r := &recorder{target, make(map[int][]byte)}
+		constr.Traced(r).Encrypt(make([]byte, 16), plaintext)
+
+		plaintexts, outputs = append(plaintexts, plaintext), append(outputs, r.outputs)
+		return nil
+	}
+
+	if err := encrypt(); err != nil {
+		return nil, err
+	}
+	width := 0
+	for pos := 0; pos < 16; pos++ {
+		if n := len(target.features(outputs[0][target.Position(pos)])); n > width {
+			width = n
+		}
+	}
+	if width == 1 {
+		return nil, errors.New("Target doesn't select any lookups!")
+	}
+
+	for len(plaintexts) < width+64 {
+		if err := encrypt(); err != nil {
+			return nil, err
+		}
+	}
+
+	key := make([]byte, 16)
+	for pos := 0; pos < 16; pos++ {
+		columns := make([]matrix.Row, width)
+		for i := range columns {
+			columns[i] = matrix.NewRow(len(plaintexts))
+		}
+
+		for n, output := range outputs {
+			for i, bit := range target.features(output[target.Position(pos)]) {
+				columns[i].SetBit(n, bit)
+			}
+		}
+
+		guess, ok := solve(columns, plaintexts, pos)
+		if !ok {
+			return nil, errors.New("Couldn't recover a byte of the key!")
+		}
+		key[pos] = guess
+	}
+
+	return key, nil
+}
+
+// solve returns the only guess for key byte pos for which every bit of the S-box's output over the plaintexts is a
+// linear combination of the columns.
+func solve(columns []matrix.Row, plaintexts [][]byte, pos int) (byte, bool) {
+	span := matrix.NewIncrementalMatrix(len(plaintexts))
+	for _, column := range columns {
+		span.Add(column)
+	}
+
+	candidates := []byte{}
+
+	for guess := 0; guess < 256; guess++ {
+		ok := true
+
+		for bit := uint(0); bit < 8 && ok; bit++ {
+			predicted := matrix.NewRow(len(plaintexts))
+			for n, plaintext := range plaintexts {
+				predicted.SetBit(n, saes.SBox[plaintext[pos]^byte(guess)]>>bit&1 == 1)
+			}
+
+			ok = span.IsIn(predicted)
+		}
+
+		if ok {
+			candidates = append(candidates, byte(guess))
+		}
+	}
+
+	if len(candidates) != 1 {
+		return 0, false
+	}
+	return candidates[0], true
+}
@@ -0,0 +1,56 @@
+package lda
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/chow"
+	"github.com/OpenWhiteBox/AES/constructions/common"
+	"github.com/OpenWhiteBox/AES/constructions/xiao"
+)
+
+var (
+	key  = []byte{72, 101, 108, 108, 111, 32, 87, 111, 114, 108, 100, 33, 33, 33, 33, 33}
+	seed = []byte{38, 41, 142, 156, 29, 181, 23, 194, 21, 250, 223, 183, 210, 168, 214, 145}
+)
+
+func TestRecoverChowKey(t *testing.T) {
+	constr, _, _ := chow.GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.IdentityMask, common.RandomMask})
+
+	cand, err := RecoverKey(&constr, Chow, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(cand, key) {
+		t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, cand)
+	}
+}
+
+func TestRecoverChowKeyExternal(t *testing.T) {
+	constr, _, _ := chow.GenerateEncryptionKeys(key, seed, common.ExternalEncodings{
+		common.IndependentMasks{common.IdentityMask, common.RandomMask}, common.NonlinearEncoding, common.NoEncoding,
+	})
+
+	if _, err := RecoverKey(&constr, Chow, rand.New(rand.NewSource(1))); err == nil {
+		t.Fatal("Recovered a key through a nonlinear external encoding!")
+	}
+}
+
+func TestRecoverXiaoKey(t *testing.T) {
+	constr, _, _ := xiao.GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.IdentityMask, common.RandomMask})
+
+	cand, err := RecoverKey(&constr, Xiao, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(cand, key) {
+		t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, cand)
+	}
+}
+
+func TestRecoverKeyShortRead(t *testing.T) {
+	constr, _, _ := xiao.GenerateEncryptionKeys(key, seed, common.IndependentMasks{common.IdentityMask, common.RandomMask})
+
+	if _, err := RecoverKey(&constr, Xiao, bytes.NewReader(make([]byte, 100))); err == nil {
+		t.Fatal("RecoverKey didn't return an error when it ran out of plaintexts!")
+	}
+}
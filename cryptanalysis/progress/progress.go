@@ -0,0 +1,28 @@
+// Package progress lets long-running cryptanalyses report how far along they are. Attacks are split into phases, and
+// report how many of a phase's steps are done as they finish them.
+package progress
+
+// Phase is one of the phases of an attack.
+type Phase string
+
+const (
+	// Decomposition recovers the layers of a construction's rounds, up to unknown encodings.
+	Decomposition Phase = "decomposition"
+
+	// Disambiguation removes the unknown encodings from the decomposed layers.
+	Disambiguation Phase = "disambiguation"
+
+	// KeyExtraction searches for the key that's consistent with the disambiguated layers.
+	KeyExtraction Phase = "key extraction"
+)
+
+// Func is called with the number of steps of a phase that are done, out of total. It's called with done = 0 when a
+// phase starts and done = total when it ends.
+type Func func(phase Phase, done, total int)
+
+// Report calls f, unless it's nil.
+func (f Func) Report(phase Phase, done, total int) {
+	if f != nil {
+		f(phase, done, total)
+	}
+}
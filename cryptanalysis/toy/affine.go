@@ -51,15 +51,18 @@ func (al *affineLayer) cleanParasites(in, nextIn [16]*parasite) {
 }
 
 // parasites returns the input parasite for each byte of the input of al.
+// Columns are handled in parallel.
 func (al *affineLayer) parasites() (input [16]*parasite) {
-	for col := 0; col < 16; col++ {
+	parallel(16, func(col int) {
 		for row := 0; row < 16; row++ {
 			input[col] = al.blockParasite(row, col)
 			if input[col] != nil {
 				break
 			}
 		}
+	})
 
+	for col := 0; col < 16; col++ {
 		if input[col] == nil {
 			panic("one column of matrix has all zero blocks")
 		}
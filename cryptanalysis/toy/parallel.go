@@ -0,0 +1,26 @@
+package toy
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallel calls f(i) for every i in [0, n) across as many goroutines as Go will run at once, and returns when every
+// call has. Calls start in increasing order of i.
+func parallel(n int, f func(i int)) {
+	next := int64(-1)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -55,65 +55,108 @@ func (sm smallMatrix) swapCols(x, y int) {
 
 // unpermute transforms sm until it is equal to a compressed round matrix. sm is mutated to the compressed round matrix,
 // and permIn and permOut are the permutations that moved it there.
+//
+// The choices for the first step are searched in parallel, each on its own copy of sm. The first choice that leads to
+// a solution is kept, so the result is the same as searching them in order.
 func (sm smallMatrix) unpermute() (permIn, permOut *permutation) {
-	permIn, permOut = newPermutation(), newPermutation()
-	ok := sm.unpermuteStep(0, permIn, permOut)
-	if !ok {
-		panic("unable to unpermute matrix")
+	twos := sm.twos(0)
+
+	type solution struct {
+		sm              smallMatrix
+		permIn, permOut *permutation
+	}
+	sols := make([]*solution, len(twos))
+
+	parallel(len(twos), func(i int) {
+		cand := sm.dup()
+		candIn, candOut := newPermutation(), newPermutation()
+
+		if cand.tryTwo(0, twos[i], candIn, candOut) {
+			sols[i] = &solution{cand, candIn, candOut}
+		}
+	})
+
+	for _, sol := range sols {
+		if sol != nil {
+			copy(sm, sol.sm)
+			return sol.permIn, sol.permOut
+		}
 	}
-	return
+
+	panic("unable to unpermute matrix")
 }
 
-// unpermuteStep is one step of the dynamic programming algorithm. It works by moving different twos to the diagonal and
-// checking if this leads to a partially correct matrix.
-func (sm smallMatrix) unpermuteStep(step int, permIn, permOut *permutation) bool {
-	if step == 16 {
-		return true
+// dup returns a copy of sm.
+func (sm smallMatrix) dup() smallMatrix {
+	out := make(smallMatrix, len(sm))
+	for row := range sm {
+		out[row] = append(out[row], sm[row]...)
 	}
 
-	// Build a list of all twos below.
-	twos := [][2]int{}
+	return out
+}
+
+// twos returns the positions of all twos below and to the right of the given step of the diagonal.
+func (sm smallMatrix) twos(step int) [][2]int {
+	out := [][2]int{}
 	for row := step; row < 16; row++ {
 		for col := step; col < 16; col++ {
 			if sm[row][col] == 2 {
-				twos = append(twos, [2]int{row, col})
+				out = append(out, [2]int{row, col})
 			}
 		}
 	}
 
-	// Foreach two, try to put it in the diagonal. Check neighboring entries for correctness. Recurse.
-	for _, two := range twos {
-		// Put this two in the diagonal.
-		sm.swapRows(step, two[0])
-		sm.swapCols(step, two[1])
-
-		permIn.swap(step, two[1])
-		permOut.swap(step, two[0])
+	return out
+}
 
-		// Check for correctness.
-		ok := true
-		for row := 0; row < step; row++ {
-			ok = ok && sm[row][step] == smallRound[row][step]
-		}
-		for col := 0; col < step; col++ {
-			ok = ok && sm[step][col] == smallRound[step][col]
-		}
-		if !ok {
-			continue
-		}
+// unpermuteStep is one step of the dynamic programming algorithm. It works by moving different twos to the diagonal and
+// checking if this leads to a partially correct matrix.
+func (sm smallMatrix) unpermuteStep(step int, permIn, permOut *permutation) bool {
+	if step == 16 {
+		return true
+	}
 
-		// Recurse down.
-		if sm.unpermuteStep(step+1, permIn, permOut) {
+	// Foreach two, try to put it in the diagonal.
+	for _, two := range sm.twos(step) {
+		if sm.tryTwo(step, two, permIn, permOut) {
 			return true
 		}
+	}
 
-		// Couldn't find lower solution. Undo swaps.
-		sm.swapRows(step, two[0])
-		sm.swapCols(step, two[1])
+	return false
+}
+
+// tryTwo puts the given two in the diagonal at step, checks neighboring entries for correctness, and recurses. It
+// undoes its swaps if this doesn't lead to a solution.
+func (sm smallMatrix) tryTwo(step int, two [2]int, permIn, permOut *permutation) bool {
+	// Put this two in the diagonal.
+	sm.swapRows(step, two[0])
+	sm.swapCols(step, two[1])
+
+	permIn.swap(step, two[1])
+	permOut.swap(step, two[0])
 
-		permIn.swap(step, two[1])
-		permOut.swap(step, two[0])
+	// Check for correctness.
+	ok := true
+	for row := 0; row < step; row++ {
+		ok = ok && sm[row][step] == smallRound[row][step]
 	}
+	for col := 0; col < step; col++ {
+		ok = ok && sm[step][col] == smallRound[step][col]
+	}
+
+	// Recurse down.
+	if ok && sm.unpermuteStep(step+1, permIn, permOut) {
+		return true
+	}
+
+	// Couldn't find lower solution. Undo swaps.
+	sm.swapRows(step, two[0])
+	sm.swapCols(step, two[1])
+
+	permIn.swap(step, two[1])
+	permOut.swap(step, two[0])
 
 	return false
 }
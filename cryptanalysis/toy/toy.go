@@ -4,35 +4,31 @@
 package toy
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
 	"github.com/OpenWhiteBox/primitives/encoding"
 
-	"github.com/OpenWhiteBox/AES/constructions/saes"
 	"github.com/OpenWhiteBox/AES/constructions/toy"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/keyschedule"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/progress"
 )
 
-var powx = [16]byte{0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x1b, 0x36, 0x6c, 0xd8, 0xab, 0x4d, 0x9a, 0x2f}
-
-// backOneRound takes round key i and returns round key i-1.
-func backOneRound(roundKey [16]byte, round int) (out [16]byte) {
-	constr := saes.Construction{}
-
-	// Recover everything except the first word by XORing consecutive blocks.
-	for pos := 4; pos < 16; pos++ {
-		out[pos] = roundKey[pos] ^ roundKey[pos-4]
-	}
-
-	// Recover the first word by XORing the first block of the roundKey with f(last block of roundKey), where f is a
-	// subroutine of AES' key scheduling algorithm.
-	for pos := 0; pos < 4; pos++ {
-		out[pos] = roundKey[pos] ^ constr.SubByte(out[12+(pos+1)%4])
-	}
-	out[0] ^= powx[round-1]
-
-	return
+// RecoverKey returns the AES key used to generate the given white-box construction, or nil if it fails.
+func RecoverKey(constr *toy.Construction) []byte {
+	key, _ := RecoverKeyContext(context.Background(), constr, nil)
+	return key
 }
 
-// RecoverKey returns the AES key used to generate the given white-box construction.
-func RecoverKey(constr *toy.Construction) []byte {
+// RecoverKeyContext is RecoverKey, but stops with ctx's error when ctx is done and reports its progress to report, which
+// may be nil. It's checked for cancellation between guesses of the permutation, and report is never called
+// concurrently. There's no decomposition phase, because the toy construction's layers are given.
+func RecoverKeyContext(ctx context.Context, constr *toy.Construction, report progress.Func) ([]byte, error) {
+	report.Report(progress.Disambiguation, 0, 1)
+
 	var (
 		target = affineLayer(constr[1]) // The layer we intend to fully disambiguate.
 		aux1   = affineLayer(constr[2]) // Lets us learn the parasites of target, and the key material's permutation.
@@ -62,24 +58,43 @@ func RecoverKey(constr *toy.Construction) []byte {
 		key2[pos] = aux1.BlockAdditive[pos] ^ 0x63
 	}
 
+	report.Report(progress.Disambiguation, 1, 1)
+
 	// The linear part of target is correct, but its constant part is not. Take a guess for how the constant part is
-	// permuted, and check if this agrees with aux1.
-	for per := 0; per < 256; per++ {
+	// permuted, and check if this agrees with aux1. Guesses are tried in parallel, but the first one that works is the
+	// one that's kept, so the result is the same as trying them in order.
+	var (
+		sols  [256]*[]byte
+		first = int64(256) // The smallest guess that's worked so far.
+
+		mu   sync.Mutex
+		done = 0
+	)
+
+	report.Report(progress.KeyExtraction, 0, 256)
+	parallel(256, func(per int) {
+		defer func() {
+			mu.Lock()
+			done++
+			report.Report(progress.KeyExtraction, done, 256)
+			mu.Unlock()
+		}()
+
+		if ctx.Err() != nil || int64(per) > atomic.LoadInt64(&first) {
+			return
+		}
+
 		perm := [4]int{(per >> 0) & 3, (per >> 2) & 3, (per >> 4) & 3, (per >> 6) & 3}
 
 		// Packing all the permutation information into one byte means that we get degenerate cases where the
 		// requested "permutation" is not a bijection. Skip those cases.
-		skip := false
 		for i := 0; i < 3; i++ {
 			for j := i + 1; j < 4; j++ {
 				if perm[i] == perm[j] {
-					skip = true
+					return
 				}
 			}
 		}
-		if skip {
-			continue
-		}
 
 		for rot := 0; rot < 256; rot++ {
 			rots := [4]int{(rot >> 0) & 3, (rot >> 2) & 3, (rot >> 4) & 3, (rot >> 6) & 3}
@@ -90,12 +105,30 @@ func RecoverKey(constr *toy.Construction) []byte {
 				encoding.InverseBlock{aux1.BlockLinear}, guess, round,
 			}.Encode(key2)
 
-			if cand1 == backOneRound(cand2, 2) {
-				sol := backOneRound(cand1, 1)
-				return sol[:]
+			sol, err := keyschedule.RecoverKey(16, 2, cand2[:])
+			if err == nil && bytes.Equal(keyschedule.Expand(sol)[1], cand1[:]) {
+				sols[per] = &sol
+
+				for f := atomic.LoadInt64(&first); int64(per) < f; f = atomic.LoadInt64(&first) {
+					if atomic.CompareAndSwapInt64(&first, f, int64(per)) {
+						break
+					}
+				}
+
+				return
 			}
 		}
+	})
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, sol := range sols {
+		if sol != nil {
+			return *sol, nil
+		}
 	}
 
-	return nil
+	return nil, errors.New("Key extraction failed!")
 }
@@ -4,9 +4,12 @@ import (
 	"testing"
 
 	"bytes"
+	"context"
 	"crypto/rand"
 
 	"github.com/OpenWhiteBox/AES/constructions/toy"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/internal/benchtest"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/progress"
 )
 
 func TestRecoverKey(t *testing.T) {
@@ -20,3 +23,36 @@ func TestRecoverKey(t *testing.T) {
 		t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, cand)
 	}
 }
+
+func TestRecoverKeyContext(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := toy.GenerateKeys(key, key)
+
+	// Cancel the attack as soon as it starts searching for the key.
+	ctx, cancel := context.WithCancel(context.Background())
+	phases := []progress.Phase{}
+
+	cand, err := RecoverKeyContext(ctx, &constr, func(phase progress.Phase, done, total int) {
+		if len(phases) == 0 || phases[len(phases)-1] != phase {
+			phases = append(phases, phase)
+		}
+		if phase == progress.KeyExtraction {
+			cancel()
+		}
+	})
+
+	if err != context.Canceled || cand != nil {
+		t.Fatalf("Attack wasn't cancelled! %x, %v", cand, err)
+	} else if len(phases) != 2 || phases[0] != progress.Disambiguation || phases[1] != progress.KeyExtraction {
+		t.Fatalf("Wrong phases reported! %v", phases)
+	}
+}
+
+func BenchmarkRecoverKey(b *testing.B) {
+	key := make([]byte, 16)
+	constr, _, _ := toy.GenerateKeys(key, key)
+
+	benchtest.Procs(b, func() { RecoverKey(&constr) })
+}
@@ -0,0 +1,37 @@
+package trace
+
+import (
+	"crypto/cipher"
+
+	"github.com/OpenWhiteBox/AES/constructions/common"
+)
+
+// Traceable is a construction that can be traced. The chow, xiao, toy, and full constructions are all Traceable.
+type Traceable interface {
+	cipher.Block
+	Traced(t common.Tracer) cipher.Block
+}
+
+// recorder is a Tracer that records every byte output by a lookup.
+type recorder struct {
+	samples []float64
+}
+
+func (r *recorder) OnLookup(_, _ int, _ string, _, out []byte) {
+	for _, b := range out {
+		r.samples = append(r.samples, float64(b))
+	}
+}
+
+func (r *recorder) OnState(_ int, _ []byte) {}
+
+// Collect encrypts plaintext with constr and returns its trace. The samples are the bytes output by each of constr's
+// lookups, in the order they happen, so they should be stored as Uint8.
+func Collect(constr Traceable, plaintext []byte) Trace {
+	r := &recorder{}
+
+	ciphertext := make([]byte, constr.BlockSize())
+	constr.Traced(r).Encrypt(ciphertext, plaintext)
+
+	return Trace{append([]byte{}, plaintext[:constr.BlockSize()]...), ciphertext, r.samples}
+}
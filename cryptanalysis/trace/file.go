@@ -0,0 +1,151 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var magic = []byte("OWBT")
+
+// Writer writes traces to a stream in this package's format: the magic string "OWBT", the header, then each trace's
+// plaintext, ciphertext, and samples. Integers in the header and samples are big-endian.
+type Writer struct {
+	w      *bufio.Writer
+	header Header
+	n      int
+}
+
+// NewWriter writes the header to w and returns a Writer for the traces that follow it.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if err := h.valid(); err != nil {
+		return nil, err
+	} else if len(h.Construction) > 0xffff {
+		return nil, errors.New("Construction name is too long!")
+	}
+
+	out := &Writer{w: bufio.NewWriter(w), header: h}
+
+	buff := append([]byte{}, magic...)
+	buff = append(buff, byte(len(h.Construction)>>8), byte(len(h.Construction)))
+	buff = append(buff, h.Construction...)
+	buff = append(buff, byte(h.Encoding))
+	for _, x := range []int{h.Samples, h.Traces, h.DataLength} {
+		buff = append(buff, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buff[len(buff)-4:], uint32(x))
+	}
+
+	if _, err := out.w.Write(buff); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Write appends one trace to the stream. The trace must have the lengths given in the header.
+func (w *Writer) Write(t Trace) error {
+	h := w.header
+
+	if len(t.Plaintext) != h.DataLength || len(t.Ciphertext) != h.DataLength || len(t.Samples) != h.Samples {
+		return errors.New("Trace doesn't match the header!")
+	} else if h.Traces != 0 && w.n == h.Traces {
+		return errors.New("Too many traces!")
+	}
+
+	size := h.Encoding.Size()
+	buff := make([]byte, 2*h.DataLength+size*h.Samples)
+
+	copy(buff, t.Plaintext)
+	copy(buff[h.DataLength:], t.Ciphertext)
+	for i, sample := range t.Samples {
+		h.Encoding.put(binary.BigEndian, buff[2*h.DataLength+size*i:], sample)
+	}
+
+	w.n++
+	_, err := w.w.Write(buff)
+	return err
+}
+
+// Close flushes the stream. It returns an error if the header gave a number of traces and that many weren't written. It
+// doesn't close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	} else if w.header.Traces != 0 && w.n != w.header.Traces {
+		return errors.New("Too few traces!")
+	}
+
+	return nil
+}
+
+// Reader reads traces written by a Writer.
+type Reader struct {
+	Header Header
+
+	r *bufio.Reader
+	n int
+}
+
+// NewReader reads the header from r and returns a Reader for the traces that follow it.
+func NewReader(r io.Reader) (*Reader, error) {
+	out := &Reader{r: bufio.NewReader(r)}
+	fail := errors.New("Parsing the trace header failed!")
+
+	buff := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(out.r, buff); err != nil || !bytes.Equal(buff[:len(magic)], magic) {
+		return nil, fail
+	}
+
+	name := make([]byte, int(buff[len(magic)])<<8|int(buff[len(magic)+1]))
+	buff = make([]byte, 1+3*4)
+	if _, err := io.ReadFull(out.r, name); err != nil {
+		return nil, fail
+	} else if _, err := io.ReadFull(out.r, buff); err != nil {
+		return nil, fail
+	}
+
+	out.Header = Header{
+		Construction: string(name),
+		Encoding:     Encoding(buff[0]),
+		Samples:      int(int32(binary.BigEndian.Uint32(buff[1:]))),
+		Traces:       int(int32(binary.BigEndian.Uint32(buff[5:]))),
+		DataLength:   int(int32(binary.BigEndian.Uint32(buff[9:]))),
+	}
+	if out.Header.valid() != nil {
+		return nil, fail
+	}
+
+	return out, nil
+}
+
+// Read returns the next trace in the stream, or io.EOF if there are none left.
+func (r *Reader) Read() (Trace, error) {
+	h := r.Header
+	size := h.Encoding.Size()
+	length := 2*h.DataLength + size*h.Samples
+
+	if h.Traces != 0 && r.n == h.Traces || length == 0 {
+		return Trace{}, io.EOF
+	}
+
+	buff, err := readBytes(r.r, length)
+	if err == io.EOF && h.Traces == 0 {
+		return Trace{}, io.EOF
+	} else if err != nil {
+		return Trace{}, errors.New("Parsing the trace failed!")
+	}
+
+	t := Trace{
+		Plaintext:  buff[:h.DataLength],
+		Ciphertext: buff[h.DataLength : 2*h.DataLength],
+		Samples:    make([]float64, h.Samples),
+	}
+	for i := range t.Samples {
+		t.Samples[i] = h.Encoding.get(binary.BigEndian, buff[2*h.DataLength+size*i:])
+	}
+
+	r.n++
+	return t, nil
+}
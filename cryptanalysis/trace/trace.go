@@ -0,0 +1,149 @@
+// Package trace stores software traces of white-box constructions: for each encryption, its plaintext, its ciphertext,
+// and a series of samples, like the values a construction looked up in its tables. Traces are written to and read from
+// a stream one at a time, so a file of traces never has to fit in memory, and can be converted to and from Inspector's
+// .trs format.
+package trace
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Encoding is the way samples are stored in a file.
+type Encoding byte
+
+const (
+	Uint8   Encoding = iota + 1 // One unsigned byte.
+	Int8                        // One signed byte.
+	Int16                       // Two bytes, as a signed integer.
+	Int32                       // Four bytes, as a signed integer.
+	Float32                     // Four bytes, as an IEEE 754 float.
+)
+
+// Size returns the number of bytes one sample takes up, or 0 if the encoding is unknown.
+func (e Encoding) Size() int {
+	switch e {
+	case Uint8, Int8:
+		return 1
+	case Int16:
+		return 2
+	case Int32, Float32:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// put encodes sample into dst with the given byte order.
+func (e Encoding) put(order binary.ByteOrder, dst []byte, sample float64) {
+	switch e {
+	case Uint8:
+		dst[0] = byte(sample)
+	case Int8:
+		dst[0] = byte(int8(sample))
+	case Int16:
+		order.PutUint16(dst, uint16(int16(sample)))
+	case Int32:
+		order.PutUint32(dst, uint32(int32(sample)))
+	case Float32:
+		order.PutUint32(dst, math.Float32bits(float32(sample)))
+	}
+}
+
+// get decodes a sample from src with the given byte order.
+func (e Encoding) get(order binary.ByteOrder, src []byte) float64 {
+	switch e {
+	case Uint8:
+		return float64(src[0])
+	case Int8:
+		return float64(int8(src[0]))
+	case Int16:
+		return float64(int16(order.Uint16(src)))
+	case Int32:
+		return float64(int32(order.Uint32(src)))
+	case Float32:
+		return float64(math.Float32frombits(order.Uint32(src)))
+	default:
+		return 0
+	}
+}
+
+// Header describes a file of traces.
+type Header struct {
+	Construction string   // The name of the construction the traces were taken from.
+	Encoding     Encoding // How samples are stored.
+	Samples      int      // The number of samples in each trace.
+	Traces       int      // The number of traces in the file, or 0 if it isn't known when the file is started.
+	DataLength   int      // The length of each trace's plaintext, and of its ciphertext.
+}
+
+func (h Header) valid() error {
+	if h.Encoding.Size() == 0 {
+		return errors.New("Unknown sample encoding!")
+	} else if h.Samples < 0 || h.Traces < 0 || h.DataLength < 0 {
+		return errors.New("Header has a negative length!")
+	}
+
+	return nil
+}
+
+// chunkSize is the most readBytes allocates before it has seen the bytes it allocated for.
+const chunkSize = 1 << 16
+
+// readBytes reads exactly n bytes from r. The lengths it's given come from headers that may be corrupt or malicious, so
+// it reads them a chunk at a time and only allocates as much as r actually holds. Like io.ReadFull, it returns io.EOF
+// only if nothing was read.
+func readBytes(r io.Reader, n int) ([]byte, error) {
+	out := []byte{}
+
+	for len(out) < n {
+		start, chunk := len(out), n-len(out)
+		if chunk > chunkSize {
+			chunk = chunkSize
+		}
+
+		out = append(out, make([]byte, chunk)...)
+		if _, err := io.ReadFull(r, out[start:]); err == io.EOF && start > 0 {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// Trace is one encryption.
+type Trace struct {
+	Plaintext, Ciphertext []byte
+	Samples               []float64
+}
+
+// Source is anything traces can be read from, one at a time. Read returns io.EOF after the last trace.
+type Source interface {
+	Read() (Trace, error)
+}
+
+// Sink is anything traces can be written to, one at a time.
+type Sink interface {
+	Write(t Trace) error
+}
+
+// Copy writes every trace in src to dst, until src returns io.EOF. It returns the number of traces copied.
+func Copy(dst Sink, src Source) (n int, err error) {
+	for {
+		t, err := src.Read()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		if err := dst.Write(t); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,178 @@
+package trace
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/OpenWhiteBox/AES/constructions/toy"
+)
+
+var (
+	key  = []byte{72, 101, 108, 108, 111, 32, 87, 111, 114, 108, 100, 33, 33, 33, 33, 33}
+	seed = []byte{38, 41, 142, 156, 29, 181, 23, 194, 21, 250, 223, 183, 210, 168, 214, 145}
+)
+
+// collect returns n traces of the toy construction, on plaintexts 0, 1, ..., n-1 in the first byte.
+func collect(n int) (Header, []Trace) {
+	constr, _, _ := toy.GenerateKeys(key, seed)
+
+	traces := make([]Trace, n)
+	for i := range traces {
+		plaintext := make([]byte, 16)
+		plaintext[0] = byte(i)
+
+		traces[i] = Collect(&constr, plaintext)
+	}
+
+	return Header{"Toy", Uint8, len(traces[0].Samples), n, 16}, traces
+}
+
+func readAll(t *testing.T, src Source) (out []Trace) {
+	for {
+		tr, err := src.Read()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		out = append(out, tr)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	constr, _, _ := toy.GenerateKeys(key, seed)
+	_, traces := collect(1)
+
+	ciphertext := make([]byte, 16)
+	constr.Encrypt(ciphertext, traces[0].Plaintext)
+
+	if !bytes.Equal(ciphertext, traces[0].Ciphertext) {
+		t.Fatalf("Real disagrees with result! %x != %x", ciphertext, traces[0].Ciphertext)
+	} else if len(traces[0].Samples) != 160 {
+		t.Fatalf("Trace has the wrong number of samples! %v != 160", len(traces[0].Samples))
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	header, traces := collect(5)
+
+	for _, count := range []int{5, 0} {
+		header.Traces = count
+		buff := &bytes.Buffer{}
+
+		w, err := NewWriter(buff, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range traces {
+			if err := w.Write(tr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(buff)
+		if err != nil {
+			t.Fatal(err)
+		} else if r.Header != header {
+			t.Fatalf("Parsed header disagrees with the original! %v != %v", r.Header, header)
+		}
+
+		if parsed := readAll(t, r); !reflect.DeepEqual(parsed, traces) {
+			t.Fatalf("Parsed traces disagree with the originals!")
+		}
+	}
+}
+
+func TestTRS(t *testing.T) {
+	header, traces := collect(5)
+	header.Encoding = Float32
+
+	native := &bytes.Buffer{}
+	w, _ := NewWriter(native, header)
+	for _, tr := range traces {
+		w.Write(tr)
+	}
+	w.Close()
+
+	trs, back := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := ExportTRS(trs, native); err != nil {
+		t.Fatal(err)
+	} else if err := ImportTRS(back, trs); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(back)
+	if err != nil {
+		t.Fatal(err)
+	} else if r.Header != header {
+		t.Fatalf("Imported header disagrees with the original! %v != %v", r.Header, header)
+	}
+
+	if parsed := readAll(t, r); !reflect.DeepEqual(parsed, traces) {
+		t.Fatalf("Imported traces disagree with the originals!")
+	}
+}
+
+func TestTRSWidening(t *testing.T) {
+	header, traces := collect(5)
+
+	native := &bytes.Buffer{}
+	w, _ := NewWriter(native, header)
+	for _, tr := range traces {
+		w.Write(tr)
+	}
+	w.Close()
+
+	trs, back := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := ExportTRS(trs, native); err != nil {
+		t.Fatal(err)
+	} else if err := ImportTRS(back, trs); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(back)
+	if err != nil {
+		t.Fatal(err)
+	} else if r.Header.Encoding != Int16 {
+		t.Fatalf("Uint8 samples weren't widened to Int16! %v", r.Header.Encoding)
+	}
+
+	if parsed := readAll(t, r); !reflect.DeepEqual(parsed, traces) {
+		t.Fatalf("Imported traces disagree with the originals!")
+	}
+}
+
+func TestHugeHeaders(t *testing.T) {
+	// A header that claims each trace is gigabytes long, followed by a short trace.
+	native := &bytes.Buffer{}
+	w, _ := NewWriter(native, Header{"Toy", Float32, 1 << 30, 1, 16})
+	w.Close()
+	native.Write(make([]byte, 100))
+
+	r, err := NewReader(native)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := r.Read(); err == nil || err == io.EOF {
+		t.Fatalf("Read accepted a truncated trace! %v", err)
+	}
+
+	// A .trs tag that claims to be gigabytes long.
+	trs := bytes.NewBuffer([]byte{trsDescription, 0x84, 0xff, 0xff, 0xff, 0x7f, 'T', 'o', 'y'})
+	if _, err := NewTRSReader(trs); err == nil {
+		t.Fatal("NewTRSReader accepted a truncated tag!")
+	}
+}
+
+func TestTRSDataLength(t *testing.T) {
+	if _, err := NewTRSWriter(&bytes.Buffer{}, Header{"Toy", Uint8, 1, 1, 0x8000}); err == nil {
+		t.Fatal("NewTRSWriter accepted data too long for its header!")
+	} else if _, err := NewTRSWriter(&bytes.Buffer{}, Header{"Toy", Uint8, 1, 1, 0x7fff}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,279 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Tags in the header of a .trs file.
+const (
+	trsTraces      = 0x41
+	trsSamples     = 0x42
+	trsCoding      = 0x43
+	trsDataLength  = 0x44
+	trsTitleSpace  = 0x45
+	trsDescription = 0x47
+	trsTraceBlock  = 0x5f
+)
+
+// trsCodings maps sample encodings to .trs sample codings. Uint8 samples don't fit in any .trs coding of the same size,
+// so they're widened to Int16.
+var trsCodings = map[Encoding]byte{Uint8: 0x02, Int8: 0x01, Int16: 0x02, Int32: 0x04, Float32: 0x14}
+
+// TRSWriter writes traces to a stream in Inspector's .trs format. A trace's data is its plaintext followed by its
+// ciphertext, and its title is empty.
+//
+// Uint8 samples are written as Int16, because .trs has no unsigned 8-bit coding. The file doesn't record that they
+// were Uint8, so a TRSReader reads them back as Int16 samples. Their values are unchanged, but they take twice the space.
+type TRSWriter struct {
+	w        *bufio.Writer
+	header   Header
+	encoding Encoding
+	n        int
+}
+
+// NewTRSWriter writes the header of a .trs file to w and returns a TRSWriter for the traces that follow it. The .trs
+// format needs the number of traces up front, so h.Traces can't be 0, and stores the length of a trace's data in 16 bits,
+// so 2*h.DataLength can't be more than 0xffff.
+func NewTRSWriter(w io.Writer, h Header) (*TRSWriter, error) {
+	if err := h.valid(); err != nil {
+		return nil, err
+	} else if h.Traces == 0 {
+		return nil, errors.New("A .trs file needs the number of traces up front!")
+	} else if 2*h.DataLength > 0xffff {
+		return nil, errors.New("Data is too long for a .trs file!")
+	}
+
+	out := &TRSWriter{w: bufio.NewWriter(w), header: h, encoding: h.Encoding}
+	if out.encoding == Uint8 {
+		out.encoding = Int16
+	}
+
+	buff := []byte{}
+	tag := func(tag byte, value []byte) {
+		buff = append(buff, tag)
+
+		if len(value) < 0x80 {
+			buff = append(buff, byte(len(value)))
+		} else {
+			buff = append(buff, 0x84, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(buff[len(buff)-4:], uint32(len(value)))
+		}
+
+		buff = append(buff, value...)
+	}
+	integer := func(x, size int) []byte {
+		out := make([]byte, 4)
+		binary.LittleEndian.PutUint32(out, uint32(x))
+		return out[:size]
+	}
+
+	tag(trsTraces, integer(h.Traces, 4))
+	tag(trsSamples, integer(h.Samples, 4))
+	tag(trsCoding, []byte{trsCodings[h.Encoding]})
+	tag(trsDataLength, integer(2*h.DataLength, 2))
+	tag(trsTitleSpace, []byte{0})
+	tag(trsDescription, []byte(h.Construction))
+	tag(trsTraceBlock, nil)
+
+	if _, err := out.w.Write(buff); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Write appends one trace to the stream. The trace must have the lengths given in the header.
+func (w *TRSWriter) Write(t Trace) error {
+	h := w.header
+
+	if len(t.Plaintext) != h.DataLength || len(t.Ciphertext) != h.DataLength || len(t.Samples) != h.Samples {
+		return errors.New("Trace doesn't match the header!")
+	} else if w.n == h.Traces {
+		return errors.New("Too many traces!")
+	}
+
+	size := w.encoding.Size()
+	buff := make([]byte, 2*h.DataLength+size*h.Samples)
+
+	copy(buff, t.Plaintext)
+	copy(buff[h.DataLength:], t.Ciphertext)
+	for i, sample := range t.Samples {
+		w.encoding.put(binary.LittleEndian, buff[2*h.DataLength+size*i:], sample)
+	}
+
+	w.n++
+	_, err := w.w.Write(buff)
+	return err
+}
+
+// Close flushes the stream. It returns an error if fewer traces were written than the header said. It doesn't close
+// the underlying io.Writer.
+func (w *TRSWriter) Close() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	} else if w.n != w.header.Traces {
+		return errors.New("Too few traces!")
+	}
+
+	return nil
+}
+
+// TRSReader reads traces from a .trs file. The first half of each trace's data is taken to be its plaintext and the
+// second half its ciphertext. Titles are skipped.
+type TRSReader struct {
+	Header Header
+
+	r          *bufio.Reader
+	titleSpace int
+	n          int
+}
+
+// NewTRSReader reads the header of a .trs file from r and returns a TRSReader for the traces that follow it.
+func NewTRSReader(r io.Reader) (*TRSReader, error) {
+	out := &TRSReader{r: bufio.NewReader(r)}
+	fail := errors.New("Parsing the .trs header failed!")
+
+	codings := map[byte]Encoding{0x01: Int8, 0x02: Int16, 0x04: Int32, 0x14: Float32}
+	dataLength := 0
+
+	for {
+		tag, err := out.r.ReadByte()
+		if err != nil {
+			return nil, fail
+		}
+
+		length, err := trsLength(out.r)
+		if err != nil {
+			return nil, fail
+		}
+
+		value, err := readBytes(out.r, length)
+		if err != nil {
+			return nil, fail
+		}
+
+		integer := func() int {
+			buff := make([]byte, 4)
+			copy(buff, value)
+			return int(int32(binary.LittleEndian.Uint32(buff)))
+		}
+
+		switch tag {
+		case trsTraces:
+			out.Header.Traces = integer()
+		case trsSamples:
+			out.Header.Samples = integer()
+		case trsCoding:
+			out.Header.Encoding = codings[byte(integer())]
+		case trsDataLength:
+			dataLength = integer()
+		case trsTitleSpace:
+			out.titleSpace = integer()
+		case trsDescription:
+			out.Header.Construction = string(value)
+		}
+
+		if tag == trsTraceBlock {
+			break
+		}
+	}
+
+	if dataLength%2 != 0 || out.titleSpace < 0 {
+		return nil, fail
+	}
+	out.Header.DataLength = dataLength / 2
+
+	if out.Header.valid() != nil {
+		return nil, fail
+	}
+
+	return out, nil
+}
+
+// trsLength reads the length of a tag's value: one byte, or if its top bit is set, that many (up to 4) little-endian
+// bytes after it.
+func trsLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	} else if b&0x80 == 0 {
+		return int(b), nil
+	}
+
+	n := int(b & 0x7f)
+	if n > 4 {
+		return 0, errors.New("Tag is too long!")
+	}
+
+	buff := make([]byte, 4)
+	if _, err := io.ReadFull(r, buff[:n]); err != nil {
+		return 0, err
+	}
+
+	return int(binary.LittleEndian.Uint32(buff)), nil
+}
+
+// Read returns the next trace in the file, or io.EOF if there are none left.
+func (r *TRSReader) Read() (Trace, error) {
+	h := r.Header
+	if r.n == h.Traces {
+		return Trace{}, io.EOF
+	}
+
+	size := h.Encoding.Size()
+
+	buff, err := readBytes(r.r, r.titleSpace+2*h.DataLength+size*h.Samples)
+	if err != nil {
+		return Trace{}, errors.New("Parsing the trace failed!")
+	}
+	buff = buff[r.titleSpace:]
+
+	t := Trace{
+		Plaintext:  buff[:h.DataLength],
+		Ciphertext: buff[h.DataLength : 2*h.DataLength],
+		Samples:    make([]float64, h.Samples),
+	}
+	for i := range t.Samples {
+		t.Samples[i] = h.Encoding.get(binary.LittleEndian, buff[2*h.DataLength+size*i:])
+	}
+
+	r.n++
+	return t, nil
+}
+
+// ExportTRS converts a file in this package's format to a .trs file. The number of traces in src must be known.
+func ExportTRS(dst io.Writer, src io.Reader) error {
+	r, err := NewReader(src)
+	if err != nil {
+		return err
+	}
+
+	w, err := NewTRSWriter(dst, r.Header)
+	if err != nil {
+		return err
+	} else if _, err := Copy(w, r); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// ImportTRS converts a .trs file to a file in this package's format.
+func ImportTRS(dst io.Writer, src io.Reader) error {
+	r, err := NewTRSReader(src)
+	if err != nil {
+		return err
+	}
+
+	w, err := NewWriter(dst, r.Header)
+	if err != nil {
+		return err
+	} else if _, err := Copy(w, r); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
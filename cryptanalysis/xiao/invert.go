@@ -0,0 +1,106 @@
+package xiao
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sort"
+
+	"github.com/OpenWhiteBox/primitives/matrix"
+
+	"github.com/OpenWhiteBox/AES/constructions/xiao"
+)
+
+// halfColumn inverts one column of a round: two tables that expand each half of the column into a word, whose outputs
+// are XORed. It meets in the middle: for every guess of the second half, it looks up what the first half must have
+// output.
+type halfColumn struct {
+	first [65536][4]byte
+
+	// second holds the second table's output on every input, in its high 32 bits, and the input in its low 16. It's
+	// sorted.
+	second []uint64
+}
+
+func newHalfColumn(first, second func([2]byte) [4]byte) *halfColumn {
+	hc := &halfColumn{second: make([]uint64, 0, 65536)}
+
+	for x := 0; x < 65536; x++ {
+		in := [2]byte{byte(x >> 8), byte(x)}
+		hc.first[x] = first(in)
+
+		w := second(in)
+		hc.second = append(hc.second, uint64(w[0])<<56|uint64(w[1])<<48|uint64(w[2])<<40|uint64(w[3])<<32|uint64(x))
+	}
+	sort.Slice(hc.second, func(i, j int) bool { return hc.second[i] < hc.second[j] })
+
+	return hc
+}
+
+// invert finds the column that maps to out.
+func (hc *halfColumn) invert(out []byte) (in [4]byte, ok bool) {
+	for x := 0; x < 65536; x++ {
+		w := hc.first[x]
+		sum := uint64(w[0]^out[0])<<24 | uint64(w[1]^out[1])<<16 | uint64(w[2]^out[2])<<8 | uint64(w[3]^out[3])
+
+		i := sort.Search(len(hc.second), func(i int) bool { return hc.second[i]>>32 >= sum })
+		if i < len(hc.second) && hc.second[i]>>32 == sum {
+			y := hc.second[i] & 0xffff
+			return [4]byte{byte(x >> 8), byte(x), byte(y >> 8), byte(y)}, true
+		}
+	}
+
+	return
+}
+
+// inverted is an encryption construction that can also decrypt.
+type inverted struct {
+	*xiao.Construction
+
+	barriers [10]matrix.Matrix
+	rounds   [10][4]*halfColumn // [round][column]
+	epilogue matrix.Matrix
+}
+
+// Invert returns a cipher.Block that encrypts with an encryption construction and decrypts with its inverse. The
+// inverse is computed from the construction's tables alone, so it needs neither the key nor the external masks:
+// decryption is no harder than encryption.
+func Invert(constr *xiao.Construction) (cipher.Block, error) {
+	out := &inverted{Construction: constr}
+
+	var ok bool
+	for round := 0; round < 10; round++ {
+		if out.barriers[round], ok = constr.ShiftRows[round].Invert(); !ok {
+			return nil, errors.New("Barrier isn't invertible!")
+		}
+
+		for col := 0; col < 4; col++ {
+			out.rounds[round][col] = newHalfColumn(constr.TBoxMixCol[round][2*col].Get, constr.TBoxMixCol[round][2*col+1].Get)
+		}
+	}
+
+	if out.epilogue, ok = constr.FinalMask.Invert(); !ok {
+		return nil, errors.New("Final mask isn't invertible!")
+	}
+
+	return out, nil
+}
+
+// Decrypt decrypts the first block in src into dst. Dst and src may point at the same memory.
+func (inv *inverted) Decrypt(dst, src []byte) {
+	state := make([]byte, 16)
+	copy(state, inv.epilogue.Mul(matrix.Row(src[:16])))
+
+	for round := 9; round >= 0; round-- {
+		for col := 0; col < 4; col++ {
+			in, ok := inv.rounds[round][col].invert(state[4*col : 4*col+4])
+			if !ok {
+				panic("Decryption failed!")
+			}
+			copy(state[4*col:], in[:])
+		}
+
+		copy(state, inv.barriers[round].Mul(matrix.Row(state)))
+	}
+
+	copy(dst, state)
+}
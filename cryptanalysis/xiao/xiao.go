@@ -6,37 +6,21 @@
 package xiao
 
 import (
+	"context"
+	"crypto/cipher"
+	"errors"
+
 	"github.com/OpenWhiteBox/primitives/encoding"
 
 	"github.com/OpenWhiteBox/AES/constructions/saes"
 	"github.com/OpenWhiteBox/AES/constructions/xiao"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/keyschedule"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/progress"
 
 	cspn "github.com/OpenWhiteBox/Generic/constructions/spn"
 	aspn "github.com/OpenWhiteBox/Generic/cryptanalysis/spn"
 )
 
-var powx = [16]byte{0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x1b, 0x36, 0x6c, 0xd8, 0xab, 0x4d, 0x9a, 0x2f}
-
-// backOneRound takes round key i and returns round key i-1.
-func backOneRound(roundKey []byte, round int) (out []byte) {
-	out = make([]byte, 16)
-	constr := saes.Construction{}
-
-	// Recover everything except the first word by XORing consecutive blocks.
-	for pos := 4; pos < 16; pos++ {
-		out[pos] = roundKey[pos] ^ roundKey[pos-4]
-	}
-
-	// Recover the first word by XORing the first block of the roundKey with f(last block of roundKey), where f is a
-	// subroutine of AES' key scheduling algorithm.
-	for pos := 0; pos < 4; pos++ {
-		out[pos] = roundKey[pos] ^ constr.SubByte(out[12+(pos+1)%4])
-	}
-	out[0] ^= powx[round-1]
-
-	return
-}
-
 // shiftrows implements a Block encoding over the ShiftRows operation.
 type shiftrows struct{}
 
@@ -58,30 +42,47 @@ func (sr shiftrows) Decode(in [16]byte) (out [16]byte) {
 	return
 }
 
-// round isolates one round of encryption with an AES white-box.
-type round struct {
-	construction *xiao.Construction
-	round        int
+// RecoverKey returns the AES key used to generate the given white-box construction.
+func RecoverKey(constr *xiao.Construction) []byte {
+	key, _ := RecoverKeyContext(context.Background(), constr, nil)
+	return key
+}
+
+// RecoverKeyContext is RecoverKey, but stops with ctx's error when ctx is done and reports its progress to report, which
+// may be nil. It's checked for cancellation before and between phases. The decomposition can't be interrupted, and it
+// takes most of the attack's time, so cancelling it can take as long as the decomposition.
+func RecoverKeyContext(ctx context.Context, constr *xiao.Construction, report progress.Func) ([]byte, error) {
+	return RecoverKeyFromRound(ctx, constr, 1, report)
 }
 
-func (r round) Encrypt(dst, src []byte) {
-	copy(dst[0:16], src[0:16])
+// Rounds is the part of a construction that the attack uses. *xiao.Construction implements it, but so can a partial
+// construction that only has some rounds' tables.
+type Rounds interface {
+	Round(i int) cipher.Block
+	Barrier(i int) cipher.Block
+}
 
-	for pos := 0; pos < 16; pos += 4 {
-		stretched := r.construction.ExpandWord(r.construction.TBoxMixCol[r.round][pos/2:(pos+4)/2], dst[pos:pos+4])
-		r.construction.SquashWords(stretched, dst[pos:pos+4])
+// RecoverKeyFromRound is RecoverKeyContext, but attacks the given round, for 0 <= round < 9, instead of round 1. It
+// only uses Round(round) and Barrier(round+1), so constr may be missing every other round. Round 0's key can't be told
+// apart from an affine external encoding on the input, which is cancelled in it: if there is one, the key that's
+// returned is off by the encoding's constant under the input mask. The other rounds never see external encodings.
+func RecoverKeyFromRound(ctx context.Context, constr Rounds, round int, report progress.Func) ([]byte, error) {
+	if round < 0 || round >= 9 {
+		return nil, errors.New("Round is out of range!")
 	}
-}
+	target, barrier := constr.Round(round), constr.Barrier(round+1)
 
-// RecoverKey returns the AES key used to generate the given white-box construction.
-func RecoverKey(constr *xiao.Construction) []byte {
-	round1 := round{
-		construction: constr,
-		round:        1,
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	// Decomposition Phase
-	constr1 := aspn.DecomposeSPN(round1, cspn.ASA)
+	report.Report(progress.Decomposition, 0, 1)
+	constr1 := aspn.DecomposeSPN(target, cspn.ASA)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	report.Report(progress.Decomposition, 1, 1)
 
 	var (
 		first, last = affineLayer(constr1[0].(encoding.BlockAffine)), affineLayer(constr1[2].(encoding.BlockAffine))
@@ -89,6 +90,8 @@ func RecoverKey(constr *xiao.Construction) []byte {
 	)
 
 	// Disambiguation Phase
+	report.Report(progress.Disambiguation, 0, 1)
+
 	// The SPN decomposition naturally leaves the last affine layer without a constant part. We would push it into the
 	// middle S-boxes if that wasn't the case.
 
@@ -111,7 +114,7 @@ func RecoverKey(constr *xiao.Construction) []byte {
 	last.leftCompose(out)
 
 	// Add ShiftRows matrix to make search possible.
-	last.rightCompose(encoding.NewBlockLinear(constr.ShiftRows[2]))
+	last.rightCompose(aspn.Encoding{barrier})
 
 	// Clean off remaining noise from self-equivalences of Sbar.
 	left := last.cleanLeft()
@@ -129,17 +132,26 @@ func RecoverKey(constr *xiao.Construction) []byte {
 
 	// fmt.Println(encoding.ProbablyEquivalentBlocks(
 	//   encoding.ComposedBlocks{first, middle, last},
-	//   encoding.ComposedBlocks{aspn.Encoding{round1}, encoding.NewBlockLinear(constr.ShiftRows[2])},
+	//   encoding.ComposedBlocks{aspn.Encoding{target}, aspn.Encoding{barrier}},
 	// ))
 	// fmt.Println(encoding.ProbablyEquivalentBlocks(
 	//   aspn.Encoding{constr1},
-	//   aspn.Encoding{round1},
+	//   aspn.Encoding{target},
 	// ))
 	//
 	// Output:
 	//   true
 	//   true
 
+	report.Report(progress.Disambiguation, 1, 1)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// The first round key is the constant of the first affine layer, so extracting it is only one step.
+	report.Report(progress.KeyExtraction, 0, 1)
 	roundKey := shiftrows{}.Decode(first.BlockAdditive)
-	return backOneRound(roundKey[:], 1)
+	report.Report(progress.KeyExtraction, 1, 1)
+
+	return keyschedule.RecoverKey(16, round, roundKey[:])
 }
@@ -2,11 +2,14 @@ package xiao
 
 import (
 	"bytes"
+	"context"
+	"crypto/cipher"
 	"crypto/rand"
 	"testing"
 
 	"github.com/OpenWhiteBox/AES/constructions/common"
 	"github.com/OpenWhiteBox/AES/constructions/xiao"
+	"github.com/OpenWhiteBox/AES/cryptanalysis/progress"
 )
 
 func TestRecoverKey(t *testing.T) {
@@ -29,3 +32,134 @@ func TestRecoverKey(t *testing.T) {
 		t.Fatal("Generated key does not equal recovered key!")
 	}
 }
+
+func TestRecoverKeyContext(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := xiao.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	// A context that's already done stops the attack before it starts.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cand, err := RecoverKeyContext(ctx, &constr, func(_ progress.Phase, _, _ int) {
+		t.Fatal("Cancelled attack reported progress!")
+	})
+	if err != context.Canceled || cand != nil {
+		t.Fatalf("Attack wasn't cancelled! %x, %v", cand, err)
+	}
+
+	// Otherwise, the attack stops once the decomposition it's in finishes.
+	ctx, cancel = context.WithCancel(context.Background())
+	phases := []progress.Phase{}
+
+	cand, err = RecoverKeyContext(ctx, &constr, func(phase progress.Phase, done, total int) {
+		if len(phases) == 0 || phases[len(phases)-1] != phase {
+			phases = append(phases, phase)
+		}
+		cancel()
+	})
+
+	if err != context.Canceled || cand != nil {
+		t.Fatalf("Attack wasn't cancelled! %x, %v", cand, err)
+	} else if len(phases) != 1 || phases[0] != progress.Decomposition {
+		t.Fatalf("Wrong phases reported! %v", phases)
+	}
+}
+
+func TestRecoverKeyExternal(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	// Xiao-Lai's outermost layers are linear, so its external encodings can only be affine.
+	constr, _, _ := xiao.GenerateEncryptionKeys(key, key, common.ExternalEncodings{
+		common.IndependentMasks{common.RandomMask, common.RandomMask}, common.AffineEncoding, common.AffineEncoding,
+	})
+
+	cand := RecoverKey(&constr)
+
+	if !bytes.Equal(cand, key) {
+		t.Fatalf("Recovered wrong key!\nreal=%x\ncand=%x", key, cand)
+	}
+}
+
+// partial is a construction that only ships some of its rounds.
+type partial struct {
+	rounds, barriers map[int]cipher.Block
+}
+
+func (p partial) Round(i int) cipher.Block {
+	if round, ok := p.rounds[i]; ok {
+		return round
+	}
+
+	panic("round was stripped")
+}
+
+func (p partial) Barrier(i int) cipher.Block {
+	if barrier, ok := p.barriers[i]; ok {
+		return barrier
+	}
+
+	panic("barrier was stripped")
+}
+
+func TestRecoverKeyFromRound(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := xiao.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	for _, round := range []int{0, 4, 8} {
+		stripped := partial{
+			rounds:   map[int]cipher.Block{round: constr.Round(round)},
+			barriers: map[int]cipher.Block{round + 1: constr.Barrier(round + 1)},
+		}
+
+		cand, err := RecoverKeyFromRound(context.Background(), stripped, round, nil)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(cand, key) {
+			t.Fatalf("Recovered wrong key from round %v!\nreal=%x\ncand=%x", round, key, cand)
+		}
+	}
+}
+
+func TestInvert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the inversion test in short mode!")
+	}
+
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	constr, _, _ := xiao.GenerateEncryptionKeys(
+		key, key, common.IndependentMasks{common.RandomMask, common.RandomMask},
+	)
+
+	// Only the serialized tables are public.
+	parsed, err := xiao.Parse(constr.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inverted, err := Invert(&parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, output, cand := make([]byte, 16), make([]byte, 16), make([]byte, 16)
+	rand.Read(input)
+
+	inverted.Encrypt(output, input)
+	inverted.Decrypt(cand, output)
+
+	if !bytes.Equal(input, cand) {
+		t.Fatalf("Decryption disagrees with encryption! %x != %x", input, cand)
+	}
+}